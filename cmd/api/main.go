@@ -1,16 +1,40 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"deployment-platform/internal/config"
 	"deployment-platform/internal/database"
+	"deployment-platform/internal/handlers/admin"
+	"deployment-platform/internal/handlers/alias"
+	"deployment-platform/internal/handlers/credential"
 	"deployment-platform/internal/handlers/deployer"
+	"deployment-platform/internal/handlers/domain"
+	"deployment-platform/internal/handlers/envvar"
+	"deployment-platform/internal/handlers/project"
 	"deployment-platform/internal/handlers/user"
+	"deployment-platform/internal/handlers/webhook"
 	"deployment-platform/internal/middleware"
+	"deployment-platform/internal/queue"
+	"deployment-platform/internal/secrets"
 	"deployment-platform/internal/services"
+	adminService "deployment-platform/internal/services/admin"
+	aliasService "deployment-platform/internal/services/alias"
+	"deployment-platform/internal/services/builder"
+	credentialService "deployment-platform/internal/services/credential"
 	deployerService "deployment-platform/internal/services/deployer"
+	domainService "deployment-platform/internal/services/domain"
+	envvarService "deployment-platform/internal/services/envvar"
+	projectService "deployment-platform/internal/services/project"
 	userService "deployment-platform/internal/services/user"
+	webhookService "deployment-platform/internal/services/webhook"
+	"deployment-platform/internal/storage"
 
 	"github.com/gin-gonic/gin"
 
@@ -22,6 +46,9 @@ func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// Connect to database
 	db := database.Connect(cfg)
 
@@ -29,30 +56,77 @@ func main() {
 	database.AutoMigrate(db)
 
 	// Initialize infrastructure services
-	s3Service := services.NewS3Service(cfg)
-
-	rabbitMQ, err := services.NewRabbitMQ(cfg.RabbitMQURL)
+	objectStore, err := storage.New(cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+		log.Fatalf("Failed to initialize object storage: %v", err)
+	}
+	redisService := services.NewRedisService(cfg.RedisURL)
+	secretBox, err := secrets.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize secrets: %v", err)
 	}
-	defer rabbitMQ.Close()
 
-	// Initialize domain services
+	jobQueue, err := queue.New(cfg, db, "deployments")
+	if err != nil {
+		log.Fatalf("Failed to connect to job queue: %v", err)
+	}
+	defer jobQueue.Close()
 
 	// Initialize WebSocket Hub
 	hub := websocket.NewHub()
 	go hub.Run()
 
-	// DeployService needs: db, rmq, s3, hub
-	deployServiceCore := services.NewDeployService(db, rabbitMQ, s3Service, hub)
+	// Build logs arrive from workers over Redis
+	go services.RelayLogs(ctx, redisService, hub)
+
+	// The API only publishes jobs; cmd/worker builds them unless the
+	// in-process worker is enabled for development
+	if cfg.QueueDriver == queue.DriverMemory && !cfg.WorkerInProcess {
+		log.Println("WARNING: QUEUE_DRIVER=memory without WORKER_IN_PROCESS=true, jobs will never be built")
+	}
+	var buildRunner builder.BuildRunner
+	if cfg.WorkerInProcess {
+		buildRunner, err = builder.NewRunner(cfg)
+		if err != nil {
+			log.Fatalf("Failed to create build runner: %v", err)
+		}
+	}
+
+	logPublisher := services.NewRedisLogPublisher(redisService)
+	deployServiceCore := services.NewDeployService(db, jobQueue, objectStore, redisService, logPublisher, buildRunner, secretBox, services.NewWorkerOptions(cfg))
+
+	workerDone := make(chan struct{})
+	if cfg.WorkerInProcess {
+		log.Println("Starting in-process deploy worker (WORKER_IN_PROCESS=true)")
+		go func() {
+			deployServiceCore.StartWorker(ctx)
+			close(workerDone)
+		}()
+	} else {
+		close(workerDone)
+	}
 
 	usrService := userService.NewService(db)
+	admService := adminService.NewService(db, deployServiceCore)
 	depService := deployerService.NewService(db, deployServiceCore, cfg.BaseDomain)
+	projService := projectService.NewService(db, deployServiceCore, redisService, cfg.BaseDomain)
+	aliService := aliasService.NewService(db, redisService, cfg.BaseDomain)
+	domService := domainService.NewService(db, redisService, net.DefaultResolver, cfg.BaseDomain)
+	hookService := webhookService.NewService(db, depService)
+	credService := credentialService.NewService(db, secretBox)
+	envService := envvarService.NewService(db, secretBox)
 
 	// Initialize handlers
 	userHandler := user.NewHandler(usrService)
-	deployHandler := deployer.NewHandler(depService)
-	websocketHandler := wsHandler.NewHandler(hub)
+	deployHandler := deployer.NewHandler(depService, int64(cfg.UploadMaxSize)<<20)
+	projectHandler := project.NewHandler(projService)
+	aliasHandler := alias.NewHandler(aliService)
+	domainHandler := domain.NewHandler(domService)
+	webhookHandler := webhook.NewHandler(hookService)
+	credentialHandler := credential.NewHandler(credService)
+	envVarHandler := envvar.NewHandler(envService)
+	websocketHandler := wsHandler.NewHandler(hub, depService)
+	adminHandler := admin.NewHandler(admService)
 
 	r := gin.Default()
 	r.Use(middleware.CORS())
@@ -65,18 +139,82 @@ func main() {
 		auth.POST("/login", userHandler.Login)
 	}
 
+	// Git hosts authenticate with per-project signatures instead of JWTs
+	r.POST("/webhooks/:provider", webhookHandler.Receive)
+
 	api := r.Group("/")
 	api.Use(middleware.Auth())
 	{
 		api.POST("/deploy", deployHandler.Deploy)
+		api.POST("/deployments/upload", deployHandler.Upload)
 		api.GET("/deployments", deployHandler.GetDeployments)
 		api.GET("/deployments/:id", deployHandler.GetStatus)
 		api.DELETE("/deployments/:id", deployHandler.DeleteDeployment)
+		api.POST("/deployments/:id/cancel", deployHandler.CancelDeployment)
+		api.POST("/deployments/:id/redeploy", deployHandler.Redeploy)
 		api.GET("/deployments/:id/logs", websocketHandler.HandleLogs)
+
+		api.POST("/projects", projectHandler.CreateProject)
+		api.GET("/projects", projectHandler.GetProjects)
+		api.GET("/projects/:id", projectHandler.GetProject)
+		api.PATCH("/projects/:id", projectHandler.UpdateProject)
+		api.DELETE("/projects/:id", projectHandler.DeleteProject)
+		api.GET("/projects/:id/deployments", projectHandler.GetDeployments)
+		api.GET("/projects/:id/webhook", projectHandler.Webhook)
+		api.POST("/projects/:id/webhook", projectHandler.Webhook)
+
+		api.POST("/aliases", aliasHandler.CreateAlias)
+		api.GET("/aliases", aliasHandler.GetAliases)
+		api.GET("/aliases/:name", aliasHandler.GetAlias)
+		api.PUT("/aliases/:name", aliasHandler.UpdateAlias)
+		api.DELETE("/aliases/:name", aliasHandler.DeleteAlias)
+		api.POST("/aliases/:name/rollback", aliasHandler.Rollback)
+		api.GET("/aliases/:name/history", aliasHandler.GetHistory)
+
+		api.POST("/domains", domainHandler.AddDomain)
+		api.GET("/domains", domainHandler.GetDomains)
+		api.GET("/domains/:hostname", domainHandler.GetDomain)
+		api.PUT("/domains/:hostname", domainHandler.UpdateDomain)
+		api.DELETE("/domains/:hostname", domainHandler.RemoveDomain)
+		api.POST("/domains/:hostname/verify", domainHandler.VerifyDomain)
+
+		api.POST("/credentials", credentialHandler.CreateCredential)
+		api.GET("/credentials", credentialHandler.GetCredentials)
+		api.GET("/credentials/:id", credentialHandler.GetCredential)
+		api.DELETE("/credentials/:id", credentialHandler.DeleteCredential)
+
+		api.POST("/env-vars", envVarHandler.CreateEnvVar)
+		api.GET("/env-vars", envVarHandler.GetEnvVars)
+		api.GET("/env-vars/:id", envVarHandler.GetEnvVar)
+		api.PATCH("/env-vars/:id", envVarHandler.UpdateEnvVar)
+		api.DELETE("/env-vars/:id", envVarHandler.DeleteEnvVar)
 	}
 
-	log.Printf("Server starting on port 8080")
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal("Failed to start server:", err)
+	adminAPI := r.Group("/admin")
+	adminAPI.Use(middleware.AdminAuth(cfg.AdminToken))
+	{
+		adminAPI.GET("/dead-letters", adminHandler.ListDeadLetters)
+		adminAPI.POST("/dead-letters/replay", adminHandler.ReplayDeadLetters)
+		adminAPI.DELETE("/deployments/:id", adminHandler.PurgeDeployment)
+	}
+
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	go func() {
+		log.Printf("Server starting on port 8080")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
 	}
+
+	// Let in-flight builds finish or requeue before the broker connection closes
+	<-workerDone
 }
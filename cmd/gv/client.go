@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var errNotLoggedIn = errors.New("not logged in, run gv login or set GV_TOKEN")
+
+// client calls the deployment API with the stored token.
+type client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newClient(cfg *cliConfig) *client {
+	return &client{
+		baseURL: strings.TrimSuffix(cfg.APIURL, "/"),
+		token:   cfg.Token,
+		http:    &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+// apiError is an error response from the API.
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.Status)
+}
+
+// deployment mirrors the fields of the API's deployment records gv uses.
+type deployment struct {
+	DeployID      string    `json:"deploy_id"`
+	Status        string    `json:"status"`
+	Source        string    `json:"source"`
+	RepoURL       string    `json:"repo_url"`
+	Branch        string    `json:"branch"`
+	CommitSHA     string    `json:"commit_sha"`
+	Target        string    `json:"target"`
+	DeployedURL   string    `json:"deployed_url"`
+	UnchangedFrom string    `json:"unchanged_from"`
+	Framework     string    `json:"framework"`
+	BuildLog      string    `json:"build_log"`
+	ErrorMsg      string    `json:"error_msg"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// finished reports whether a deployment reached a final status.
+func (d *deployment) finished() bool {
+	switch d.Status {
+	case "deployed", "skipped", "failed", "cancelled":
+		return true
+	}
+	return false
+}
+
+type project struct {
+	ID            uint      `json:"id"`
+	Name          string    `json:"name"`
+	Slug          string    `json:"slug"`
+	RepoURL       string    `json:"repo_url"`
+	DefaultBranch string    `json:"default_branch"`
+	URL           string    `json:"url"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// createdDeployment is the response to creating a deployment.
+type createdDeployment struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	DeployedURL string `json:"deployed_url"`
+}
+
+// do sends a JSON request, or body as-is when it is an io.Reader, and
+// decodes the JSON response into out when it is not nil.
+func (c *client) do(method, path string, body any, out any) error {
+	return c.doWith(method, path, body, -1, "", out)
+}
+
+func (c *client) doWith(method, path string, body any, size int64, contentType string, out any) error {
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+		size = int64(len(data))
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if size >= 0 {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if json.Unmarshal(data, &e) != nil || e.Error == "" {
+			e.Error = strings.TrimSpace(string(data))
+			if e.Error == "" {
+				e.Error = http.StatusText(resp.StatusCode)
+			}
+		}
+		if resp.StatusCode == http.StatusUnauthorized && c.token == "" {
+			return errNotLoggedIn
+		}
+		return &apiError{Status: resp.StatusCode, Message: e.Error}
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *client) deployment(id string) (*deployment, error) {
+	var d deployment
+	if err := c.do(http.MethodGet, "/deployments/"+url.PathEscape(id), nil, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// wsURL turns an API path into its WebSocket URL.
+func (c *client) wsURL(path string) string {
+	base := c.baseURL
+	switch {
+	case strings.HasPrefix(base, "https://"):
+		base = "wss://" + strings.TrimPrefix(base, "https://")
+	case strings.HasPrefix(base, "http://"):
+		base = "ws://" + strings.TrimPrefix(base, "http://")
+	}
+	return base + path
+}
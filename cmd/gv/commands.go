@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
+)
+
+func runLogin(args []string) error {
+	fs := newFlagSet("login", "login [--api URL] [--email EMAIL]")
+	apiURL := fs.String("api", "", "API base URL (default: the stored one, or "+defaultAPIURL+")")
+	email := fs.String("email", "", "account email (prompted when omitted)")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if *apiURL != "" {
+		cfg.APIURL = *apiURL
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	if *email == "" {
+		fmt.Fprint(os.Stderr, "Email: ")
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		*email = strings.TrimSpace(line)
+	}
+
+	// GV_PASSWORD allows logging in non-interactively
+	password := os.Getenv("GV_PASSWORD")
+	if password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			data, err := term.ReadPassword(int(os.Stdin.Fd()))
+			fmt.Fprintln(os.Stderr)
+			if err != nil {
+				return err
+			}
+			password = string(data)
+		} else {
+			line, err := in.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			password = strings.TrimRight(line, "\r\n")
+		}
+	}
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	c := newClient(&cliConfig{APIURL: cfg.APIURL})
+	if err := c.do(http.MethodPost, "/auth/login", map[string]string{"email": *email, "password": password}, &resp); err != nil {
+		return err
+	}
+
+	cfg.Token = resp.Token
+	cfg.Email = *email
+	if err := cfg.save(); err != nil {
+		return fmt.Errorf("saving credentials: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Logged in to %s as %s\n", cfg.APIURL, *email)
+	return nil
+}
+
+func runLogout(args []string) error {
+	fs := newFlagSet("logout", "logout")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.Token, cfg.Email = "", ""
+	if err := cfg.save(); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Logged out")
+	return nil
+}
+
+func runLogs(args []string) error {
+	fs := newFlagSet("logs", "logs <id>")
+	ids, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(ids) != 1 {
+		return fmt.Errorf("%w: gv logs <id>", errUsage)
+	}
+	c, err := authedClient()
+	if err != nil {
+		return err
+	}
+	return follow(c, ids[0])
+}
+
+func runList(args []string) error {
+	fs := newFlagSet("ls", "ls [--project ID]")
+	projectID := fs.Uint("project", 0, "only list deployments of this project")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	c, err := authedClient()
+	if err != nil {
+		return err
+	}
+
+	path := "/deployments"
+	if *projectID != 0 {
+		path = fmt.Sprintf("/projects/%d/deployments", *projectID)
+	}
+	var deployments []deployment
+	if err := c.do(http.MethodGet, path, nil, &deployments); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tSOURCE\tTARGET\tAGE\tURL")
+	for _, d := range deployments {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", d.DeployID, d.Status, source(&d), dash(d.Target), age(d.CreatedAt), d.DeployedURL)
+	}
+	return tw.Flush()
+}
+
+func runRemove(args []string) error {
+	fs := newFlagSet("rm", "rm <id>...")
+	ids, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("%w: gv rm <id>...", errUsage)
+	}
+	c, err := authedClient()
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	for _, id := range ids {
+		if err := c.do(http.MethodDelete, "/deployments/"+url.PathEscape(id), nil, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "gv: deleting %s: %v\n", id, err)
+			failed = true
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Deleted %s\n", id)
+	}
+	if failed {
+		return exitError(1)
+	}
+	return nil
+}
+
+func runProjects(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			return runProjectsAdd(args[1:])
+		case "rm":
+			return runProjectsRemove(args[1:])
+		case "ls":
+			args = args[1:]
+		}
+	}
+
+	fs := newFlagSet("projects", "projects [ls | add <name> <repo-url> | rm <id>...]")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	c, err := authedClient()
+	if err != nil {
+		return err
+	}
+
+	var projects []project
+	if err := c.do(http.MethodGet, "/projects", nil, &projects); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tREPOSITORY\tBRANCH\tURL")
+	for _, p := range projects {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", p.ID, p.Name, p.RepoURL, dash(p.DefaultBranch), p.URL)
+	}
+	return tw.Flush()
+}
+
+func runProjectsAdd(args []string) error {
+	fs := newFlagSet("projects add", "projects add [--branch BRANCH] <name> <repo-url>")
+	branch := fs.String("branch", "", "production branch (default: the repository's default)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("%w: gv projects add <name> <repo-url>", errUsage)
+	}
+	c, err := authedClient()
+	if err != nil {
+		return err
+	}
+
+	body := map[string]string{"name": positional[0], "repo_url": positional[1]}
+	if *branch != "" {
+		body["default_branch"] = *branch
+	}
+	var p project
+	if err := c.do(http.MethodPost, "/projects", body, &p); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Created project %d (%s)\n", p.ID, p.URL)
+	return nil
+}
+
+func runProjectsRemove(args []string) error {
+	fs := newFlagSet("projects rm", "projects rm <id>...")
+	ids, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("%w: gv projects rm <id>...", errUsage)
+	}
+	c, err := authedClient()
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	for _, id := range ids {
+		if err := c.do(http.MethodDelete, "/projects/"+url.PathEscape(id), nil, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "gv: deleting project %s: %v\n", id, err)
+			failed = true
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Deleted project %s\n", id)
+	}
+	if failed {
+		return exitError(1)
+	}
+	return nil
+}
+
+// source describes where a deployment's files came from.
+func source(d *deployment) string {
+	if d.Source == "upload" {
+		return "upload"
+	}
+	if d.CommitSHA != "" {
+		return fmt.Sprintf("%s@%.7s", dash(d.Branch), d.CommitSHA)
+	}
+	return "git"
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// age renders how long ago t was, coarsely.
+func age(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const defaultAPIURL = "http://localhost:8080"
+
+// cliConfig is stored in the user's config directory after gv login.
+// GV_API_URL and GV_TOKEN take precedence, which suits CI jobs.
+type cliConfig struct {
+	APIURL string `json:"api_url"`
+	Token  string `json:"token"`
+	Email  string `json:"email,omitempty"`
+}
+
+func configPath() (string, error) {
+	if path := os.Getenv("GV_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gv", "config.json"), nil
+}
+
+func loadConfig() (*cliConfig, error) {
+	cfg := &cliConfig{}
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if url := os.Getenv("GV_API_URL"); url != "" {
+		cfg.APIURL = url
+	}
+	if token := os.Getenv("GV_TOKEN"); token != "" {
+		cfg.Token = token
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = defaultAPIURL
+	}
+	return cfg, nil
+}
+
+// save writes the config readable by the user only, since it holds a token.
+func (cfg *cliConfig) save() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"deployment-platform/internal/archive"
+
+	"github.com/gorilla/websocket"
+)
+
+// pollInterval is how often gv checks a followed deployment's status.
+const pollInterval = 2 * time.Second
+
+func runDeploy(args []string) error {
+	fs := newFlagSet("deploy", "deploy [flags] [dir]")
+	repo := fs.String("repo", "", "deploy this git repository instead of uploading a directory")
+	ref := fs.String("ref", "", "branch, tag or commit to deploy with --repo or a project")
+	git := fs.Bool("git", false, "deploy the project's repository instead of uploading (needs --project)")
+	projectID := fs.Uint("project", 0, "deploy to this project")
+	target := fs.String("target", "", "production or preview, for uploads to a project")
+	prebuilt := fs.Bool("prebuilt", false, "publish the directory as-is, without building it")
+	root := fs.String("root", "", "monorepo directory to build")
+	node := fs.String("node", "", "Node.js version to build with")
+	noCache := fs.Bool("no-cache", false, "build without the dependency cache (git deployments)")
+	noFollow := fs.Bool("no-follow", false, "print the deployment ID and exit without waiting")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	fromGit := *repo != "" || *git
+	switch {
+	case len(positional) > 1:
+		return fmt.Errorf("%w: gv deploy takes at most one directory", errUsage)
+	case fromGit && len(positional) > 0:
+		return fmt.Errorf("%w: a directory cannot be combined with --repo or --git", errUsage)
+	case *git && *projectID == 0:
+		return fmt.Errorf("%w: --git needs --project", errUsage)
+	case fromGit && (*prebuilt || *target != ""):
+		return fmt.Errorf("%w: --prebuilt and --target only apply to uploads", errUsage)
+	case !fromGit && (*ref != "" || *noCache):
+		return fmt.Errorf("%w: --ref and --no-cache only apply to --repo or --git", errUsage)
+	}
+
+	c, err := authedClient()
+	if err != nil {
+		return err
+	}
+
+	var created createdDeployment
+	if fromGit {
+		body := map[string]any{}
+		if *repo != "" {
+			body["repo_url"] = *repo
+		}
+		if *projectID != 0 {
+			body["project_id"] = *projectID
+		}
+		if *ref != "" {
+			body["ref"] = *ref
+		}
+		if *root != "" {
+			body["root_directory"] = *root
+		}
+		if *node != "" {
+			body["node_version"] = *node
+		}
+		if *noCache {
+			body["no_cache"] = true
+		}
+		if err := c.do(http.MethodPost, "/deploy", body, &created); err != nil {
+			return err
+		}
+	} else {
+		dir := "."
+		if len(positional) == 1 {
+			dir = positional[0]
+		}
+		query := url.Values{}
+		if *projectID != 0 {
+			query.Set("project_id", strconv.FormatUint(uint64(*projectID), 10))
+		}
+		if *target != "" {
+			query.Set("target", *target)
+		}
+		if *prebuilt {
+			query.Set("prebuilt", "true")
+		}
+		if *root != "" {
+			query.Set("root_directory", *root)
+		}
+		if *node != "" {
+			query.Set("node_version", *node)
+		}
+		if err := upload(c, dir, query, &created); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Deployment %s created\n", created.ID)
+	if *noFollow {
+		fmt.Println(created.ID)
+		return nil
+	}
+	return follow(c, created.ID)
+}
+
+// upload packs dir into a temporary tar.gz and sends it to the API. VCS
+// metadata, installed dependencies and local .env files are left out: the
+// build installs dependencies itself and environment variables are set on
+// the platform.
+func upload(c *client, dir string, query url.Values, out *createdDeployment) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	tmp, err := os.CreateTemp("", "gv-upload-*.tar.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	fmt.Fprintf(os.Stderr, "Packing %s\n", dir)
+	if err := archive.CreateTarGzFunc(tmp, dir, []string{"."}, skipUpload); err != nil {
+		return fmt.Errorf("packing %s: %w", dir, err)
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Uploading %s\n", formatSize(size))
+	path := "/deployments/upload"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.doWith(http.MethodPost, path, tmp, size, "application/gzip", out)
+}
+
+func skipUpload(name string, info fs.FileInfo) bool {
+	base := filepath.Base(name)
+	if info.IsDir() {
+		return base == ".git" || base == "node_modules"
+	}
+	return base == ".env" || (strings.HasPrefix(base, ".env.") && base != ".env.example")
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// follow streams a deployment's build logs until it finishes and prints the
+// outcome. The log socket only carries live lines, so a deployment that has
+// already finished is shown from its stored build log instead. Failed and
+// cancelled deployments end gv with status 1.
+func follow(c *client, id string) error {
+	d, err := c.deployment(id)
+	if err != nil {
+		return err
+	}
+	if d.finished() {
+		fmt.Print(d.BuildLog)
+		return result(d)
+	}
+
+	streamed := make(chan struct{})
+	conn, err := dialLogs(c, id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gv: not streaming logs: %v\n", err)
+		close(streamed)
+	} else {
+		defer conn.Close()
+		go func() {
+			defer close(streamed)
+			for {
+				_, msg, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				fmt.Println(strings.TrimRight(string(msg), "\n"))
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for !d.finished() {
+		<-ticker.C
+		next, err := c.deployment(id)
+		if err != nil {
+			var apiErr *apiError
+			if errors.As(err, &apiErr) && apiErr.Status < 500 {
+				return err
+			}
+			// Keep following through API restarts and network blips
+			continue
+		}
+		d = next
+	}
+
+	// Give the last log lines a moment to arrive before closing
+	select {
+	case <-streamed:
+	case <-time.After(time.Second):
+	}
+	if conn == nil {
+		fmt.Print(d.BuildLog)
+	}
+	return result(d)
+}
+
+func dialLogs(c *client, id string) (*websocket.Conn, error) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.token)
+	conn, resp, err := websocket.DefaultDialer.Dial(c.wsURL("/deployments/"+url.PathEscape(id)+"/logs"), header)
+	if resp != nil && resp.Body != nil {
+		resp.Body.Close()
+	}
+	return conn, err
+}
+
+// result prints how a deployment ended and whether gv should fail.
+func result(d *deployment) error {
+	switch d.Status {
+	case "deployed":
+		fmt.Fprintf(os.Stderr, "Deployed %s\n", d.DeployID)
+		fmt.Println(d.DeployedURL)
+	case "skipped":
+		fmt.Fprintf(os.Stderr, "Skipped %s: nothing changed since %s\n", d.DeployID, d.UnchangedFrom)
+		if d.DeployedURL != "" {
+			fmt.Println(d.DeployedURL)
+		}
+	case "cancelled":
+		fmt.Fprintf(os.Stderr, "Deployment %s was cancelled\n", d.DeployID)
+		return exitError(1)
+	default:
+		fmt.Fprintf(os.Stderr, "Deployment %s failed: %s\n", d.DeployID, dash(d.ErrorMsg))
+		return exitError(1)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// Command gv is a command-line client for the deployment API: it logs in,
+// deploys a directory or a git repository, follows build logs and manages
+// deployments and projects. It exits non-zero when a deployment fails, so
+// it can gate CI pipelines.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: gv <command> [flags] [args]
+
+Commands:
+  login              log in and store the token
+  logout             forget the stored token
+  deploy [dir]       upload and deploy a directory (default: current directory)
+  deploy --repo URL  deploy a git repository
+  logs <id>          follow a deployment's build logs until it finishes
+  ls                 list deployments
+  rm <id>...         delete deployments
+  projects           list projects
+  projects add <name> <repo-url>
+  projects rm <id>...
+
+Run gv <command> -h for the flags of a command. GV_API_URL and GV_TOKEN
+override the stored API URL and token.
+`
+
+// errUsage marks errors caused by bad arguments, which exit with status 2.
+var errUsage = errors.New("usage")
+
+// exitError ends gv with a status without printing anything more.
+type exitError int
+
+func (e exitError) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+
+var commands = map[string]func(args []string) error{
+	"login":    runLogin,
+	"logout":   runLogout,
+	"deploy":   runDeploy,
+	"logs":     runLogs,
+	"ls":       runList,
+	"rm":       runRemove,
+	"projects": runProjects,
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	run, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "gv: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	err := run(os.Args[2:])
+	var exit exitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exit):
+		os.Exit(int(exit))
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(2)
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(os.Stderr, "gv: %v\n", err)
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "gv: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseFlags parses flags that may come before, between or after
+// positional arguments, and returns the positional ones.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// newFlagSet returns a flag set whose parse errors are returned, not fatal.
+func newFlagSet(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gv %s\n", synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// authedClient returns an API client, failing early without a token.
+func authedClient() (*client, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Token == "" {
+		return nil, errNotLoggedIn
+	}
+	return newClient(cfg), nil
+}
@@ -2,15 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"deployment-platform/internal/certs"
 	"deployment-platform/internal/config"
+	"deployment-platform/internal/database"
+	"deployment-platform/internal/routing"
 	"deployment-platform/internal/services"
+	"deployment-platform/internal/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,30 +25,60 @@ func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
 
+	// Connect to database
+	db := database.Connect(cfg)
+
 	// Initialize services
-	s3Service := services.NewS3Service(cfg)
+	objectStore, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize object storage: %v", err)
+	}
 	redisService := services.NewRedisService(cfg.RedisURL)
+	resolver := routing.NewResolver(db, redisService, cfg.BaseDomain)
 
 	r := gin.Default()
 
 	r.GET("/*path", func(c *gin.Context) {
-		host := c.Request.Host
-		parts := strings.Split(host, ".")
-		if len(parts) < 2 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hostname"})
+		deployID, err := resolver.Resolve(c.Request.Context(), c.Request.Host)
+		if err != nil {
+			status := http.StatusNotFound
+			if errors.Is(err, routing.ErrGone) {
+				status = http.StatusGone
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
 			return
 		}
-		deployID := parts[0]
 
 		filePath := c.Param("path")
 		if filePath == "/" || filePath == "" {
 			filePath = "/index.html"
 		}
 
-		// Cache Key
-		cacheKey := fmt.Sprintf("deploy:%s:%s", deployID, filePath)
 		ctx := c.Request.Context()
 
+		// Range requests (media, resumable downloads) bypass the cache
+		if rng, ok := parseRange(c.GetHeader("Range")); ok {
+			key := fmt.Sprintf("dist/%s%s", deployID, filePath)
+			obj, err := objectStore.Get(ctx, key, rng)
+			if err != nil {
+				if errors.Is(err, storage.ErrNotFound) {
+					c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+				} else {
+					c.Status(http.StatusRequestedRangeNotSatisfiable)
+				}
+				return
+			}
+			defer obj.Body.Close()
+
+			c.Header("Accept-Ranges", "bytes")
+			c.Header("Content-Range", obj.ContentRange)
+			c.DataFromReader(http.StatusPartialContent, obj.Size, obj.ContentType, obj.Body, nil)
+			return
+		}
+
+		// Cache Key
+		cacheKey := services.DeploymentCacheKey(deployID, filePath)
+
 		// 1. Check Redis Cache
 		cachedContent, err := redisService.Get(ctx, cacheKey)
 		if err == nil {
@@ -54,14 +90,14 @@ func main() {
 			return
 		}
 
-		// 2. Cache Miss - Fetch from S3
+		// 2. Cache Miss - Fetch from object storage
 		key := fmt.Sprintf("dist/%s%s", deployID, filePath)
-		output, err := s3Service.GetObject(key)
+		output, err := objectStore.Get(ctx, key, nil)
 		if err != nil {
 			// Try fallback to index.html for SPA
 			if !strings.HasSuffix(filePath, "index.html") {
 				fallbackKey := fmt.Sprintf("dist/%s/index.html", deployID)
-				output, err = s3Service.GetObject(fallbackKey)
+				output, err = objectStore.Get(ctx, fallbackKey, nil)
 				if err != nil {
 					c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 					return
@@ -80,15 +116,14 @@ func main() {
 		// Read content
 		content, err := io.ReadAll(output.Body)
 		if err != nil {
-			log.Printf("Error reading S3 content: %v", err)
+			log.Printf("Error reading object content: %v", err)
 			c.Status(http.StatusInternalServerError)
 			return
 		}
 
 		// Set Content-Type
-		contentType := ""
-		if output.ContentType != nil {
-			contentType = *output.ContentType
+		contentType := output.ContentType
+		if contentType != "" {
 			c.Header("Content-Type", contentType)
 		}
 
@@ -105,11 +140,68 @@ func main() {
 		}()
 
 		// Stream content
+		c.Header("Accept-Ranges", "bytes")
 		c.Writer.Write(content)
 	})
 
-	log.Printf("Request handler starting on port 3001")
-	if err := r.Run(":3001"); err != nil {
-		log.Fatal("Failed to start server:", err)
+	if !cfg.TLSEnabled {
+		log.Printf("Request handler starting on port 3001")
+		if err := r.Run(":3001"); err != nil {
+			log.Fatal("Failed to start server:", err)
+		}
+		return
+	}
+
+	// Terminate TLS here, with plain HTTP kept for ACME HTTP-01 challenges
+	tlsManager, err := certs.NewManager(cfg, db, routing.Hostname(cfg.BaseDomain), resolver.IsCustomDomain)
+	if err != nil {
+		log.Fatalf("Failed to configure TLS: %v", err)
+	}
+	tlsManager.Start(context.Background())
+
+	go func() {
+		log.Printf("Request handler starting on port 3001 (HTTP)")
+		if err := http.ListenAndServe(":3001", tlsManager.HTTPHandler(r)); err != nil {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	srv := &http.Server{
+		Addr:      ":" + cfg.TLSPort,
+		Handler:   r,
+		TLSConfig: tlsManager.TLSConfig(),
+	}
+	log.Printf("Request handler starting on port %s (HTTPS)", cfg.TLSPort)
+	if err := srv.ListenAndServeTLS("", ""); err != nil {
+		log.Fatal("Failed to start TLS server:", err)
+	}
+}
+
+// parseRange understands a single "bytes=start-end" or "bytes=start-" range.
+// Anything else is served as a full response, which RFC 9110 allows.
+func parseRange(header string) (*storage.Range, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return nil, false
 	}
+
+	startStr, endStr, ok := strings.Cut(spec, "-")
+	if !ok || startStr == "" {
+		return nil, false
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return nil, false
+	}
+
+	end := int64(-1)
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return nil, false
+		}
+	}
+
+	return &storage.Range{Start: start, End: end}, true
 }
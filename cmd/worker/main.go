@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"deployment-platform/internal/config"
+	"deployment-platform/internal/database"
+	"deployment-platform/internal/queue"
+	"deployment-platform/internal/secrets"
+	"deployment-platform/internal/services"
+	"deployment-platform/internal/services/builder"
+	"deployment-platform/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	// Load configuration
+	cfg := config.LoadConfig()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Connect to database
+	db := database.Connect(cfg)
+
+	// Initialize infrastructure services
+	objectStore, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize object storage: %v", err)
+	}
+	redisService := services.NewRedisService(cfg.RedisURL)
+	secretBox, err := secrets.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize secrets: %v", err)
+	}
+
+	jobQueue, err := queue.New(cfg, db, "deployments")
+	if err != nil {
+		log.Fatalf("Failed to connect to job queue: %v", err)
+	}
+	defer jobQueue.Close()
+
+	if cfg.QueueDriver == queue.DriverMemory {
+		log.Fatal("QUEUE_DRIVER=memory only works with the in-process worker (WORKER_IN_PROCESS=true on the API)")
+	}
+
+	buildRunner, err := builder.NewRunner(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create build runner: %v", err)
+	}
+
+	logPublisher := services.NewRedisLogPublisher(redisService)
+	deployService := services.NewDeployService(db, jobQueue, objectStore, redisService, logPublisher, buildRunner, secretBox, services.NewWorkerOptions(cfg))
+
+	// Health endpoint
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.GET("/healthz", func(c *gin.Context) {
+		checkCtx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		defer cancel()
+
+		checks := gin.H{"database": "ok", "redis": "ok", "queue": "ok"}
+		healthy := true
+
+		if sqlDB, err := db.DB(); err != nil || sqlDB.PingContext(checkCtx) != nil {
+			checks["database"] = "unreachable"
+			healthy = false
+		}
+		if err := redisService.Ping(checkCtx); err != nil {
+			checks["redis"] = "unreachable"
+			healthy = false
+		}
+		if err := jobQueue.Ping(checkCtx); err != nil {
+			checks["queue"] = "unreachable"
+			healthy = false
+		}
+
+		status := http.StatusOK
+		if !healthy {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{
+			"healthy":       healthy,
+			"checks":        checks,
+			"active_builds": deployService.ActiveBuilds(),
+			"runner":        buildRunner.Name(),
+		})
+	})
+
+	srv := &http.Server{Addr: ":" + cfg.WorkerPort, Handler: r}
+	go func() {
+		log.Printf("Worker health endpoint on port %s", cfg.WorkerPort)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Failed to start health server:", err)
+		}
+	}()
+
+	// Blocks until SIGTERM and the pool has drained
+	deployService.StartWorker(ctx)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,298 @@
+// Package archive packs and unpacks directory trees. Both directions are
+// confined to a root directory with os.Root, since the trees come from
+// untrusted repositories and archives: symlinks are stored and restored as
+// links and may never lead outside the root.
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+var (
+	ErrTooLarge   = errors.New("archive exceeds the size limit")
+	ErrUnsafePath = errors.New("archive entry escapes the destination")
+)
+
+// Limits bound what an extraction may write; zero values mean no limit.
+type Limits struct {
+	MaxBytes int64 // total uncompressed size of regular files
+	MaxFiles int   // number of entries
+}
+
+// CreateTarGz writes a gzip-compressed tar of paths, given relative to root,
+// to w. Paths that don't exist are skipped.
+func CreateTarGz(w io.Writer, root string, paths []string) error {
+	return CreateTarGzFunc(w, root, paths, nil)
+}
+
+// CreateTarGzFunc is CreateTarGz leaving out every entry, and everything
+// below it, for which skip returns true. A nil skip keeps everything.
+func CreateTarGzFunc(w io.Writer, root string, paths []string, skip func(name string, info fs.FileInfo) bool) error {
+	r, err := os.OpenRoot(root)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, p := range paths {
+		p = path.Clean(strings.TrimPrefix(p, "/"))
+		if _, err := r.Lstat(p); errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := addTree(tw, r, p, skip); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addTree(tw *tar.Writer, r *os.Root, name string, skip func(string, fs.FileInfo) bool) error {
+	info, err := r.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if skip != nil && skip(name, info) {
+		return nil
+	}
+
+	var link string
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if link, err = r.Readlink(name); err != nil {
+			return err
+		}
+		if !insideRoot(name, link) {
+			return nil // would be rejected on extraction
+		}
+	} else if !info.IsDir() && !info.Mode().IsRegular() {
+		return nil // sockets, devices and the like are not worth keeping
+	}
+
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+	hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode().IsRegular():
+		f, err := r.Open(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		f.Close()
+		return err
+	case info.IsDir():
+		dir, err := r.Open(name)
+		if err != nil {
+			return err
+		}
+		entries, err := dir.ReadDir(-1)
+		dir.Close()
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := addTree(tw, r, path.Join(name, entry.Name()), skip); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ExtractTarGz unpacks a gzip-compressed tar into dest, which must exist.
+// Existing files are overwritten.
+func ExtractTarGz(src io.Reader, dest string, limits Limits) error {
+	gz, err := gzip.NewReader(src)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	return ExtractTar(gz, dest, limits)
+}
+
+// ExtractTar unpacks an uncompressed tar into dest, which must exist.
+func ExtractTar(src io.Reader, dest string, limits Limits) error {
+	r, err := os.OpenRoot(dest)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	x := &extractor{root: r, limits: limits}
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = x.dir(hdr.Name)
+		case tar.TypeReg:
+			err = x.file(hdr.Name, fs.FileMode(hdr.Mode), tr)
+		case tar.TypeSymlink:
+			err = x.symlink(hdr.Name, hdr.Linkname)
+		default:
+			// Hard links, devices and FIFOs are never needed for sites
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// extractor writes entries below root, enforcing limits across calls.
+type extractor struct {
+	root    *os.Root
+	limits  Limits
+	written int64
+	files   int
+}
+
+// Entry names are checked before os.Root sees them so unsafe archives are
+// rejected with a clear error rather than partly extracted.
+func (x *extractor) clean(name string) (string, error) {
+	x.files++
+	if x.limits.MaxFiles > 0 && x.files > x.limits.MaxFiles {
+		return "", fmt.Errorf("%w: more than %d files", ErrTooLarge, x.limits.MaxFiles)
+	}
+
+	name = strings.ReplaceAll(name, "\\", "/")
+	cleaned := path.Clean(name)
+	if path.IsAbs(name) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	return cleaned, nil
+}
+
+func (x *extractor) dir(name string) error {
+	name, err := x.clean(name)
+	if err != nil || name == "." {
+		return err
+	}
+	if err := x.parent(name); err != nil {
+		return err
+	}
+	if info, err := x.root.Lstat(name); err == nil && info.IsDir() {
+		return nil
+	}
+	if err := x.root.RemoveAll(name); err != nil {
+		return err
+	}
+	return x.root.Mkdir(name, 0o755)
+}
+
+func (x *extractor) file(name string, mode fs.FileMode, body io.Reader) error {
+	name, err := x.clean(name)
+	if err != nil {
+		return err
+	}
+	if err := x.parent(name); err != nil {
+		return err
+	}
+	// Replace rather than write through whatever is there, e.g. a symlink
+	if err := x.root.RemoveAll(name); err != nil {
+		return err
+	}
+
+	f, err := x.root.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if x.limits.MaxBytes > 0 {
+		remaining := x.limits.MaxBytes - x.written
+		n, err := io.Copy(f, io.LimitReader(body, remaining+1))
+		x.written += n
+		if err != nil {
+			return err
+		}
+		if n > remaining {
+			return fmt.Errorf("%w: more than %d bytes", ErrTooLarge, x.limits.MaxBytes)
+		}
+		return nil
+	}
+	n, err := io.Copy(f, body)
+	x.written += n
+	return err
+}
+
+func (x *extractor) symlink(name, target string) error {
+	name, err := x.clean(name)
+	if err != nil {
+		return err
+	}
+	if !insideRoot(name, target) {
+		return fmt.Errorf("%w: %s -> %s", ErrUnsafePath, name, target)
+	}
+	if err := x.parent(name); err != nil {
+		return err
+	}
+	if err := x.root.RemoveAll(name); err != nil {
+		return err
+	}
+	return x.root.Symlink(target, name)
+}
+
+// insideRoot reports whether a symlink at name pointing at target is
+// relative and resolves inside the root.
+func insideRoot(name, target string) bool {
+	resolved := path.Join(path.Dir(name), target)
+	return !path.IsAbs(target) && resolved != ".." && !strings.HasPrefix(resolved, "../")
+}
+
+// parent creates the directories above name. Existing components must be
+// real directories: writing through a symlinked directory would make the
+// lexical checks on link targets meaningless.
+func (x *extractor) parent(name string) error {
+	dir := path.Dir(name)
+	if dir == "." {
+		return nil
+	}
+
+	current := ""
+	for _, part := range strings.Split(dir, "/") {
+		current = path.Join(current, part)
+		info, err := x.root.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			return x.root.MkdirAll(dir, 0o755)
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%w: %s is not a directory", ErrUnsafePath, current)
+		}
+	}
+	return nil
+}
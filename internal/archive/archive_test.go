@@ -0,0 +1,350 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	typeFile = iota
+	typeDir
+	typeSymlink
+	typeHardlink
+)
+
+// entry is one member of a crafted archive.
+type entry struct {
+	name string
+	typ  int
+	body string // file contents, or the link target
+}
+
+func file(name, body string) entry      { return entry{name: name, typ: typeFile, body: body} }
+func dir(name string) entry             { return entry{name: name, typ: typeDir} }
+func symlink(name, target string) entry { return entry{name: name, typ: typeSymlink, body: target} }
+func hardlink(name, target string) entry {
+	return entry{name: name, typ: typeHardlink, body: target}
+}
+
+func makeTarGz(t *testing.T, entries []entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Format: tar.FormatPAX}
+		switch e.typ {
+		case typeFile:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(len(e.body))
+		case typeDir:
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0o755
+		case typeSymlink:
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = e.body
+		case typeHardlink:
+			hdr.Typeflag = tar.TypeLink
+			hdr.Linkname = e.body
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if e.typ == typeFile {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// makeZip has no hard links: zip cannot express them.
+func makeZip(t *testing.T, entries []entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		switch e.typ {
+		case typeFile:
+			hdr.SetMode(0o644)
+		case typeDir:
+			hdr.SetMode(fs.ModeDir | 0o755)
+			if !strings.HasSuffix(hdr.Name, "/") {
+				hdr.Name += "/"
+			}
+		case typeSymlink:
+			hdr.SetMode(fs.ModeSymlink | 0o777)
+		default:
+			t.Fatalf("zip cannot hold entry type %d", e.typ)
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type format struct {
+	name    string
+	build   func(*testing.T, []entry) []byte
+	extract func(data []byte, dest string, limits Limits) error
+}
+
+var formats = []format{
+	{"tar.gz", makeTarGz, func(data []byte, dest string, limits Limits) error {
+		return ExtractTarGz(bytes.NewReader(data), dest, limits)
+	}},
+	{"zip", makeZip, func(data []byte, dest string, limits Limits) error {
+		return ExtractZip(bytes.NewReader(data), int64(len(data)), dest, limits)
+	}},
+}
+
+// newDest returns an empty destination inside a parent holding a canary
+// file, so tests can tell whether extraction touched anything outside.
+func newDest(t *testing.T) (dest, parent string) {
+	t.Helper()
+	parent = t.TempDir()
+	dest = filepath.Join(parent, "dest")
+	if err := os.Mkdir(dest, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(parent, "canary"), []byte("untouched"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dest, parent
+}
+
+func checkOutsideUntouched(t *testing.T, parent string) {
+	t.Helper()
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "dest" && e.Name() != "canary" {
+			t.Errorf("extraction created %s outside the destination", e.Name())
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(parent, "canary")); string(data) != "untouched" {
+		t.Errorf("canary outside the destination was modified: %q", data)
+	}
+}
+
+func TestExtractRejectsUnsafeArchives(t *testing.T) {
+	big := strings.Repeat("\x00", 2<<20)
+	many := make([]entry, 11)
+	for i := range many {
+		many[i] = file(strings.Repeat("f", i+1), "x")
+	}
+
+	tests := []struct {
+		name    string
+		entries []entry
+		limits  Limits
+		wantErr error
+	}{
+		{name: "parent directory entry", entries: []entry{file("../canary", "owned")}, wantErr: ErrUnsafePath},
+		{name: "nested parent directory entry", entries: []entry{file("a/../../canary", "owned")}, wantErr: ErrUnsafePath},
+		{name: "backslash parent directory entry", entries: []entry{file(`..\canary`, "owned")}, wantErr: ErrUnsafePath},
+		{name: "absolute path", entries: []entry{file("/tmp/evil", "owned")}, wantErr: ErrUnsafePath},
+		{name: "directory outside", entries: []entry{dir("../evil")}, wantErr: ErrUnsafePath},
+		{name: "symlink to parent", entries: []entry{symlink("up", "..")}, wantErr: ErrUnsafePath},
+		{name: "symlink escaping through subdirectory", entries: []entry{dir("a"), symlink("a/up", "../../canary")}, wantErr: ErrUnsafePath},
+		{name: "absolute symlink", entries: []entry{symlink("etc", "/etc")}, wantErr: ErrUnsafePath},
+		{
+			// A link that stays inside can still not be used as a directory
+			name:    "write through symlinked directory",
+			entries: []entry{dir("real"), symlink("link", "real"), file("link/evil", "owned")},
+			wantErr: ErrUnsafePath,
+		},
+		{name: "size bomb", entries: []entry{file("zeros", big)}, limits: Limits{MaxBytes: 1 << 20}, wantErr: ErrTooLarge},
+		{
+			name:    "size bomb across files",
+			entries: []entry{file("a", big[:600<<10]), file("b", big[:600<<10])},
+			limits:  Limits{MaxBytes: 1 << 20},
+			wantErr: ErrTooLarge,
+		},
+		{name: "entry count bomb", entries: many, limits: Limits{MaxFiles: 10}, wantErr: ErrTooLarge},
+	}
+
+	for _, f := range formats {
+		for _, tt := range tests {
+			t.Run(f.name+"/"+tt.name, func(t *testing.T) {
+				dest, parent := newDest(t)
+				err := f.extract(f.build(t, tt.entries), dest, tt.limits)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				checkOutsideUntouched(t, parent)
+				if _, err := os.Lstat(filepath.Join(dest, "real", "evil")); err == nil {
+					t.Error("file was written through a symlink")
+				}
+			})
+		}
+	}
+}
+
+func TestExtractTarSkipsHardlinks(t *testing.T) {
+	dest, parent := newDest(t)
+	data := makeTarGz(t, []entry{
+		hardlink("passwd", "/etc/passwd"),
+		hardlink("canary", "../canary"),
+		file("index.html", "hi"),
+	})
+	if err := ExtractTarGz(bytes.NewReader(data), dest, Limits{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"passwd", "canary"} {
+		if _, err := os.Lstat(filepath.Join(dest, name)); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("hard link %s was extracted: %v", name, err)
+		}
+	}
+	checkOutsideUntouched(t, parent)
+}
+
+func TestExtractZipRejectsUnderstatedSizes(t *testing.T) {
+	// The central directory claims 10 bytes for a 2MB entry
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "zeros",
+		Method:             zip.Store,
+		CompressedSize64:   2 << 20,
+		UncompressedSize64: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(strings.Repeat("a", 2<<20)))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dest, _ := newDest(t)
+	data := buf.Bytes()
+	err = ExtractZip(bytes.NewReader(data), int64(len(data)), dest, Limits{MaxBytes: 1 << 20})
+	if err == nil {
+		t.Fatal("extracted an entry larger than its declared size")
+	}
+	if info, err := os.Stat(filepath.Join(dest, "zeros")); err == nil && info.Size() > 1<<20 {
+		t.Errorf("wrote %d bytes past the limit", info.Size())
+	}
+}
+
+func TestExtractReplacesSymlinkInsteadOfWritingThroughIt(t *testing.T) {
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			dest, _ := newDest(t)
+			data := f.build(t, []entry{
+				file("target.txt", "original"),
+				symlink("link.txt", "target.txt"),
+				file("link.txt", "replaced"),
+			})
+			if err := f.extract(data, dest, Limits{}); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := os.ReadFile(filepath.Join(dest, "target.txt")); string(got) != "original" {
+				t.Errorf("symlink target was overwritten: %q", got)
+			}
+			info, err := os.Lstat(filepath.Join(dest, "link.txt"))
+			if err != nil || !info.Mode().IsRegular() {
+				t.Errorf("link.txt is not a regular file: %v %v", info, err)
+			}
+		})
+	}
+}
+
+func TestExtractSafeArchive(t *testing.T) {
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			dest, parent := newDest(t)
+			data := f.build(t, []entry{
+				dir("assets"),
+				file("index.html", "<h1>hi</h1>"),
+				file("assets/app.js", "console.log(1)"),
+				file("deep/nested/file.txt", "deep"),
+				symlink("latest.js", "assets/app.js"),
+				symlink("assets/home.html", "../index.html"),
+			})
+			limits := Limits{MaxBytes: 1 << 20, MaxFiles: 10}
+			if err := f.extract(data, dest, limits); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, want := range map[string]string{
+				"index.html":           "<h1>hi</h1>",
+				"assets/app.js":        "console.log(1)",
+				"deep/nested/file.txt": "deep",
+				"latest.js":            "console.log(1)",
+				"assets/home.html":     "<h1>hi</h1>",
+			} {
+				got, err := os.ReadFile(filepath.Join(dest, name))
+				if err != nil || string(got) != want {
+					t.Errorf("%s = %q, %v; want %q", name, got, err, want)
+				}
+			}
+			if target, err := os.Readlink(filepath.Join(dest, "latest.js")); err != nil || target != "assets/app.js" {
+				t.Errorf("latest.js links to %q, %v", target, err)
+			}
+			checkOutsideUntouched(t, parent)
+		})
+	}
+}
+
+func TestCreateTarGzFuncRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	for name, body := range map[string]string{
+		"index.html":            "hi",
+		"node_modules/dep/x.js": "dep",
+		"src/app.js":            "app",
+		".env":                  "SECRET=1",
+	} {
+		path := filepath.Join(src, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	skip := func(name string, info fs.FileInfo) bool {
+		return name == "node_modules" || name == ".env"
+	}
+	if err := CreateTarGzFunc(&buf, src, []string{"."}, skip); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := t.TempDir()
+	if err := ExtractTarGz(&buf, dest, Limits{}); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"index.html": true, "src/app.js": true, "node_modules": false, ".env": false} {
+		_, err := os.Stat(filepath.Join(dest, name))
+		if got := err == nil; got != want {
+			t.Errorf("%s extracted = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package archive
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// maxLinkTarget bounds symlink targets, which zip stores as file contents.
+const maxLinkTarget = 4096
+
+// ExtractZip unpacks a zip archive of the given size into dest, which must
+// exist. Sizes declared in the archive are checked up front, and what is
+// actually written is counted as well, so lying headers don't get around
+// the limits.
+func ExtractZip(src io.ReaderAt, size int64, dest string, limits Limits) error {
+	zr, err := zip.NewReader(src, size)
+	if err != nil {
+		return err
+	}
+	if limits.MaxFiles > 0 && len(zr.File) > limits.MaxFiles {
+		return fmt.Errorf("%w: more than %d files", ErrTooLarge, limits.MaxFiles)
+	}
+	if limits.MaxBytes > 0 {
+		var declared uint64
+		for _, f := range zr.File {
+			declared += f.UncompressedSize64
+			if declared > uint64(limits.MaxBytes) {
+				return fmt.Errorf("%w: more than %d bytes", ErrTooLarge, limits.MaxBytes)
+			}
+		}
+	}
+
+	r, err := os.OpenRoot(dest)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	x := &extractor{root: r, limits: limits}
+	for _, f := range zr.File {
+		mode := f.Mode()
+		switch {
+		case mode.IsDir() || strings.HasSuffix(f.Name, "/"):
+			err = x.dir(f.Name)
+		case mode&fs.ModeSymlink != 0:
+			err = extractZipLink(x, f)
+		case mode.IsRegular():
+			err = extractZipFile(x, f)
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipFile(x *extractor, f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return x.file(f.Name, f.Mode(), rc)
+}
+
+func extractZipLink(x *extractor, f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	target, err := io.ReadAll(io.LimitReader(rc, maxLinkTarget+1))
+	if err != nil {
+		return err
+	}
+	if len(target) > maxLinkTarget {
+		return fmt.Errorf("%w: %s has an oversized link target", ErrUnsafePath, f.Name)
+	}
+	return x.symlink(f.Name, string(target))
+}
@@ -0,0 +1,44 @@
+package certs
+
+import (
+	"context"
+	"errors"
+
+	"deployment-platform/internal/models"
+
+	"golang.org/x/crypto/acme/autocert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DBCache is an autocert.Cache backed by Postgres, so certificates and
+// HTTP-01 challenge tokens are visible to every replica.
+type DBCache struct {
+	db *gorm.DB
+}
+
+func NewDBCache(db *gorm.DB) *DBCache {
+	return &DBCache{db: db}
+}
+
+func (c *DBCache) Get(ctx context.Context, key string) ([]byte, error) {
+	var cert models.Certificate
+	if err := c.db.WithContext(ctx).Where("key = ?", key).First(&cert).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, autocert.ErrCacheMiss
+		}
+		return nil, err
+	}
+	return cert.Data, nil
+}
+
+func (c *DBCache) Put(ctx context.Context, key string, data []byte) error {
+	return c.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"data", "updated_at"}),
+	}).Create(&models.Certificate{Key: key, Data: data}).Error
+}
+
+func (c *DBCache) Delete(ctx context.Context, key string) error {
+	return c.db.WithContext(ctx).Where("key = ?", key).Delete(&models.Certificate{}).Error
+}
@@ -0,0 +1,41 @@
+package certs
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// DNSProvider publishes the TXT records DNS-01 challenges are validated
+// against. Present must only return once the record is resolvable.
+type DNSProvider interface {
+	Present(ctx context.Context, fqdn, value string) error
+	CleanUp(ctx context.Context, fqdn, value string) error
+}
+
+// ExecDNSProvider delegates record changes to an executable, invoked as
+// "<hook> present|cleanup <fqdn> <value>", so any DNS host can be scripted.
+type ExecDNSProvider struct {
+	hook string
+}
+
+func NewExecDNSProvider(hook string) *ExecDNSProvider {
+	return &ExecDNSProvider{hook: hook}
+}
+
+func (p *ExecDNSProvider) Present(ctx context.Context, fqdn, value string) error {
+	return p.run(ctx, "present", fqdn, value)
+}
+
+func (p *ExecDNSProvider) CleanUp(ctx context.Context, fqdn, value string) error {
+	return p.run(ctx, "cleanup", fqdn, value)
+}
+
+func (p *ExecDNSProvider) run(ctx context.Context, action, fqdn, value string) error {
+	output, err := exec.CommandContext(ctx, p.hook, action, fqdn, value).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("DNS hook %s %s failed: %w: %s", action, fqdn, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
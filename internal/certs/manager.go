@@ -0,0 +1,131 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"deployment-platform/internal/config"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"gorm.io/gorm"
+)
+
+// Manager terminates TLS for the request handler. Verified custom domains
+// get individual certificates through autocert (HTTP-01 or TLS-ALPN-01);
+// hosts under the base domain share a DNS-01 wildcard certificate when a
+// DNS hook is configured.
+type Manager struct {
+	autocert *autocert.Manager
+	wildcard *WildcardManager
+	baseHost string
+}
+
+// NewManager configures ACME against cfg.ACMEDirectoryURL. allowHost
+// decides which hosts outside the base domain may get certificates.
+func NewManager(cfg *config.Config, db *gorm.DB, baseHost string, allowHost func(ctx context.Context, host string) bool) (*Manager, error) {
+	httpClient, err := acmeHTTPClient(cfg.ACMECARoots)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := NewDBCache(db)
+	m := &Manager{
+		autocert: &autocert.Manager{
+			Prompt: autocert.AcceptTOS,
+			Cache:  cache,
+			HostPolicy: func(ctx context.Context, host string) error {
+				if !allowHost(ctx, host) {
+					return fmt.Errorf("host %q is not a verified domain", host)
+				}
+				return nil
+			},
+			RenewBefore: cfg.ACMERenewBefore,
+			Email:       cfg.ACMEEmail,
+			Client: &acme.Client{
+				DirectoryURL: cfg.ACMEDirectoryURL,
+				HTTPClient:   httpClient,
+			},
+		},
+		baseHost: baseHost,
+	}
+
+	if cfg.ACMEDNSHook != "" {
+		client := &acme.Client{
+			DirectoryURL: cfg.ACMEDirectoryURL,
+			HTTPClient:   httpClient,
+		}
+		m.wildcard = NewWildcardManager(db, cache, client, NewExecDNSProvider(cfg.ACMEDNSHook), cfg.ACMEEmail, baseHost, cfg.ACMERenewBefore)
+	} else {
+		log.Printf("ACME_DNS_HOOK not set, hosts under %s will not be served over TLS", baseHost)
+	}
+
+	return m, nil
+}
+
+// Start obtains and renews the wildcard certificate in the background.
+func (m *Manager) Start(ctx context.Context) {
+	if m.wildcard != nil {
+		go m.wildcard.Run(ctx)
+	}
+}
+
+func (m *Manager) TLSConfig() *tls.Config {
+	cfg := m.autocert.TLSConfig()
+	cfg.GetCertificate = m.GetCertificate
+	return cfg
+}
+
+// HTTPHandler answers HTTP-01 challenges and passes everything else to next.
+func (m *Manager) HTTPHandler(next http.Handler) http.Handler {
+	return m.autocert.HTTPHandler(next)
+}
+
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+
+	// A wildcard covers exactly one label
+	if _, parent, ok := strings.Cut(name, "."); ok && parent == m.baseHost {
+		if m.wildcard == nil {
+			return nil, errors.New("no certificate for platform subdomains, ACME_DNS_HOOK is not configured")
+		}
+		if cert := m.wildcard.Certificate(); cert != nil {
+			return cert, nil
+		}
+		return nil, fmt.Errorf("wildcard certificate for *.%s is not available yet", m.baseHost)
+	}
+
+	return m.autocert.GetCertificate(hello)
+}
+
+// acmeHTTPClient trusts the extra CA roots in rootsFile when talking to the
+// ACME server, e.g. Pebble's test CA.
+func acmeHTTPClient(rootsFile string) (*http.Client, error) {
+	if rootsFile == "" {
+		return nil, nil
+	}
+
+	pemData, err := os.ReadFile(rootsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ACME_CA_ROOTS: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pemData) {
+		return nil, fmt.Errorf("no certificates found in %s", rootsFile)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &http.Client{Transport: transport}, nil
+}
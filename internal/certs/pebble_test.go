@@ -0,0 +1,202 @@
+//go:build pebble
+
+// Integration tests against a local Pebble ACME server, run with
+// "make test-pebble". Pebble must resolve names through pebble-challtestsrv,
+// which answers every A query with 127.0.0.1 and serves the TXT records set
+// through its management API.
+
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const pebbleBaseHost = "pebble.test"
+
+func pebbleEnv(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// pebbleClient returns an ACME client for PEBBLE_DIRECTORY_URL once the
+// server answers. Pebble's HTTPS certificate is trusted from PEBBLE_CA_ROOTS,
+// or not verified when that is unset.
+func pebbleClient(t *testing.T) *acme.Client {
+	t.Helper()
+	httpClient, err := acmeHTTPClient(os.Getenv("PEBBLE_CA_ROOTS"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if httpClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		httpClient = &http.Client{Transport: transport}
+	}
+
+	client := &acme.Client{
+		DirectoryURL: pebbleEnv("PEBBLE_DIRECTORY_URL", "https://localhost:14000/dir"),
+		HTTPClient:   httpClient,
+	}
+
+	// Pebble takes a moment to start when launched next to the tests
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		_, err := client.Discover(context.Background())
+		if err == nil {
+			return client
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Pebble is not reachable at %s: %v", client.DirectoryURL, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// challtestsrvDNS publishes DNS-01 records through pebble-challtestsrv's
+// management API and remembers what it was asked to do.
+type challtestsrvDNS struct {
+	url string
+
+	mu      sync.Mutex
+	present []string
+	cleaned []string
+}
+
+func (p *challtestsrvDNS) Present(ctx context.Context, fqdn, value string) error {
+	p.mu.Lock()
+	p.present = append(p.present, fqdn)
+	p.mu.Unlock()
+	return p.post(ctx, "/set-txt", map[string]string{"host": fqdn + ".", "value": value})
+}
+
+func (p *challtestsrvDNS) CleanUp(ctx context.Context, fqdn, value string) error {
+	p.mu.Lock()
+	p.cleaned = append(p.cleaned, fqdn)
+	p.mu.Unlock()
+	return p.post(ctx, "/clear-txt", map[string]string{"host": fqdn + "."})
+}
+
+func (p *challtestsrvDNS) post(ctx context.Context, path string, body map[string]string) error {
+	data, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("challtestsrv %s returned %s", path, resp.Status)
+	}
+	return nil
+}
+
+func TestPebbleWildcardDNS01(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	dns := &challtestsrvDNS{url: pebbleEnv("PEBBLE_CHALLTESTSRV_URL", "http://localhost:8055")}
+	cache := autocert.DirCache(t.TempDir())
+	w := NewWildcardManager(nil, cache, pebbleClient(t), dns, "", pebbleBaseHost, time.Hour)
+
+	cert, data, err := w.issue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"*." + pebbleBaseHost}; !slices.Equal(cert.Leaf.DNSNames, want) {
+		t.Errorf("certificate names = %v, want %v", cert.Leaf.DNSNames, want)
+	}
+	decoded, err := decodeCertificate(data)
+	if err != nil || decoded.Leaf.SerialNumber.Cmp(cert.Leaf.SerialNumber) != 0 {
+		t.Errorf("cache encoding does not round-trip: %v", err)
+	}
+
+	challenge := "_acme-challenge." + pebbleBaseHost
+	if !slices.Equal(dns.present, []string{challenge}) || !slices.Equal(dns.cleaned, dns.present) {
+		t.Errorf("DNS hook calls: present %v, cleanup %v; want %s once each", dns.present, dns.cleaned, challenge)
+	}
+
+	// The wildcard is served for exactly one label below the base domain
+	w.set(cert)
+	m := &Manager{wildcard: w, baseHost: pebbleBaseHost, autocert: &autocert.Manager{}}
+	got, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "app." + pebbleBaseHost})
+	if err != nil || got != cert {
+		t.Errorf("GetCertificate(app.%s) = %v, %v; want the wildcard", pebbleBaseHost, got, err)
+	}
+
+	// A second replica reuses the stored account key
+	again := NewWildcardManager(nil, cache, pebbleClient(t), dns, "", pebbleBaseHost, time.Hour)
+	if _, _, err := again.issue(ctx); err != nil {
+		t.Fatalf("issuing with the cached account key: %v", err)
+	}
+}
+
+func TestPebbleCustomDomain(t *testing.T) {
+	// Outside the base domain, so it is not routed to the wildcard
+	const host = "www.custom.test"
+
+	client := pebbleClient(t)
+	m := &Manager{
+		baseHost: pebbleBaseHost,
+		autocert: &autocert.Manager{
+			Prompt: autocert.AcceptTOS,
+			Cache:  autocert.DirCache(t.TempDir()),
+			HostPolicy: func(ctx context.Context, name string) error {
+				if name != host {
+					return fmt.Errorf("host %q is not a verified domain", name)
+				}
+				return nil
+			},
+			Client: &acme.Client{DirectoryURL: client.DirectoryURL, HTTPClient: client.HTTPClient},
+		},
+	}
+
+	// Pebble validates against whatever challtestsrv resolves host to,
+	// 127.0.0.1, on its httpPort and tlsPort
+	httpListener, err := net.Listen("tcp", ":"+pebbleEnv("PEBBLE_HTTP_PORT", "5002"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go http.Serve(httpListener, m.HTTPHandler(nil))
+	defer httpListener.Close()
+
+	tlsListener, err := tls.Listen("tcp", ":"+pebbleEnv("PEBBLE_TLS_PORT", "5001"), m.TLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go http.Serve(tlsListener, http.NotFoundHandler())
+	defer tlsListener.Close()
+
+	cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: host})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Leaf == nil {
+		t.Fatal("certificate has no parsed leaf")
+	}
+	if !slices.Equal(cert.Leaf.DNSNames, []string{host}) {
+		t.Errorf("certificate for %s has names %v", host, cert.Leaf.DNSNames)
+	}
+
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.custom.test"}); err == nil {
+		t.Error("issued a certificate for a host the policy rejects")
+	}
+}
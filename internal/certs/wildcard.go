@@ -0,0 +1,337 @@
+package certs
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"gorm.io/gorm"
+)
+
+// wildcardCheckInterval is how often replicas reload the shared wildcard
+// certificate and renew it when it gets close to expiry. Until a first
+// certificate exists they retry more often.
+const (
+	wildcardCheckInterval = time.Hour
+	wildcardRetryInterval = time.Minute
+)
+
+// WildcardManager obtains and renews *.<base domain> through DNS-01, which
+// autocert cannot do. The certificate lives in the shared cache; replicas
+// coordinate renewals with a Postgres advisory lock.
+type WildcardManager struct {
+	db          *gorm.DB
+	cache       autocert.Cache
+	client      *acme.Client
+	dns         DNSProvider
+	email       string
+	domain      string
+	renewBefore time.Duration
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func NewWildcardManager(db *gorm.DB, cache autocert.Cache, client *acme.Client, dns DNSProvider, email, baseHost string, renewBefore time.Duration) *WildcardManager {
+	return &WildcardManager{
+		db:          db,
+		cache:       cache,
+		client:      client,
+		dns:         dns,
+		email:       email,
+		domain:      "*." + baseHost,
+		renewBefore: renewBefore,
+	}
+}
+
+// Certificate returns the current wildcard certificate, nil until the first
+// one has been loaded or issued.
+func (w *WildcardManager) Certificate() *tls.Certificate {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.cert
+}
+
+// Run keeps the certificate loaded and renewed until ctx is cancelled.
+func (w *WildcardManager) Run(ctx context.Context) {
+	for {
+		if err := w.refresh(ctx); err != nil {
+			log.Printf("Wildcard certificate for %s: %v", w.domain, err)
+		}
+
+		interval := wildcardCheckInterval
+		if w.Certificate() == nil {
+			interval = wildcardRetryInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (w *WildcardManager) refresh(ctx context.Context) error {
+	cert, err := w.load(ctx)
+	if err != nil && !errors.Is(err, autocert.ErrCacheMiss) {
+		return err
+	}
+	if cert != nil {
+		w.set(cert)
+		if !w.needsRenewal(cert) {
+			return nil
+		}
+	}
+
+	return w.withLock(ctx, func() error {
+		// Another replica may have renewed while we waited for the lock
+		if cert, err := w.load(ctx); err == nil && !w.needsRenewal(cert) {
+			w.set(cert)
+			return nil
+		}
+
+		log.Printf("Requesting wildcard certificate for %s", w.domain)
+		cert, data, err := w.issue(ctx)
+		if err != nil {
+			return err
+		}
+		if err := w.cache.Put(ctx, w.cacheKey(), data); err != nil {
+			return fmt.Errorf("failed to store certificate: %w", err)
+		}
+		w.set(cert)
+		log.Printf("Obtained wildcard certificate for %s, valid until %s", w.domain, cert.Leaf.NotAfter.Format(time.RFC3339))
+		return nil
+	})
+}
+
+func (w *WildcardManager) needsRenewal(cert *tls.Certificate) bool {
+	return time.Until(cert.Leaf.NotAfter) < w.renewBefore
+}
+
+func (w *WildcardManager) set(cert *tls.Certificate) {
+	w.mu.Lock()
+	w.cert = cert
+	w.mu.Unlock()
+}
+
+func (w *WildcardManager) cacheKey() string {
+	return "wildcard+" + w.domain
+}
+
+func (w *WildcardManager) load(ctx context.Context) (*tls.Certificate, error) {
+	data, err := w.cache.Get(ctx, w.cacheKey())
+	if err != nil {
+		return nil, err
+	}
+	return decodeCertificate(data)
+}
+
+// withLock runs fn while holding a session-level advisory lock, skipping it
+// when another replica already holds the lock.
+func (w *WildcardManager) withLock(ctx context.Context, fn func() error) error {
+	h := fnv.New64a()
+	h.Write([]byte(w.cacheKey()))
+	lockID := int64(h.Sum64())
+
+	return w.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		var locked bool
+		if err := conn.Raw("SELECT pg_try_advisory_lock(?)", lockID).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", lockID)
+
+		return fn()
+	})
+}
+
+// issue runs an ACME order for the wildcard name, answering its DNS-01
+// challenge through the DNS provider. It returns the certificate and its
+// cache encoding.
+func (w *WildcardManager) issue(ctx context.Context) (*tls.Certificate, []byte, error) {
+	if err := w.register(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	order, err := w.client.AuthorizeOrder(ctx, acme.DomainIDs(w.domain))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	for _, authzURL := range order.AuthzURLs {
+		if err := w.authorize(ctx, authzURL); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	order, err = w.client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return nil, nil, fmt.Errorf("order did not become ready: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{w.domain}}, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	der, _, err := w.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to finalize order: %w", err)
+	}
+
+	data, err := encodeCertificate(key, der)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := decodeCertificate(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, data, nil
+}
+
+func (w *WildcardManager) authorize(ctx context.Context, authzURL string) error {
+	authz, err := w.client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return err
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "dns-01" {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("no dns-01 challenge offered for %s", authz.Identifier.Value)
+	}
+
+	value, err := w.client.DNS01ChallengeRecord(challenge.Token)
+	if err != nil {
+		return err
+	}
+	// Wildcard identifiers are validated on the parent name
+	fqdn := "_acme-challenge." + authz.Identifier.Value
+	if err := w.dns.Present(ctx, fqdn, value); err != nil {
+		return err
+	}
+	defer func() {
+		if err := w.dns.CleanUp(context.Background(), fqdn, value); err != nil {
+			log.Printf("Failed to clean up %s: %v", fqdn, err)
+		}
+	}()
+
+	if _, err := w.client.Accept(ctx, challenge); err != nil {
+		return fmt.Errorf("failed to accept challenge: %w", err)
+	}
+	if _, err := w.client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("authorization for %s failed: %w", authz.Identifier.Value, err)
+	}
+	return nil
+}
+
+// register loads or creates the ACME account key and registers it. The key
+// is kept apart from autocert's so the two managers never race on it.
+func (w *WildcardManager) register(ctx context.Context) error {
+	if w.client.Key != nil {
+		return nil
+	}
+
+	key, err := w.accountKey(ctx)
+	if err != nil {
+		return err
+	}
+	w.client.Key = key
+
+	account := &acme.Account{}
+	if w.email != "" {
+		account.Contact = []string{"mailto:" + w.email}
+	}
+	_, err = w.client.Register(ctx, account, acme.AcceptTOS)
+	if err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		w.client.Key = nil
+		return fmt.Errorf("failed to register ACME account: %w", err)
+	}
+	return nil
+}
+
+func (w *WildcardManager) accountKey(ctx context.Context) (crypto.Signer, error) {
+	const keyName = "wildcard+account_key"
+
+	data, err := w.cache.Get(ctx, keyName)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("invalid account key in cache")
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !errors.Is(err, autocert.ErrCacheMiss) {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.cache.Put(ctx, keyName, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// encodeCertificate uses the same layout as autocert: the private key
+// followed by the certificate chain, all PEM encoded.
+func encodeCertificate(key *ecdsa.PrivateKey, chain [][]byte) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	for _, cert := range chain {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})...)
+	}
+	return data, nil
+}
+
+func decodeCertificate(data []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cached certificate: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, err
+		}
+	}
+	return &cert, nil
+}
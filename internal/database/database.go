@@ -32,7 +32,15 @@ func Connect(cfg *config.Config) *gorm.DB {
 func AutoMigrate(db *gorm.DB) {
 	err := db.AutoMigrate(
 		&models.User{},
+		&models.GitCredential{},
+		&models.Project{},
+		&models.EnvVar{},
 		&models.Deployment{},
+		&models.Alias{},
+		&models.AliasTarget{},
+		&models.Domain{},
+		&models.Certificate{},
+		&models.Job{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
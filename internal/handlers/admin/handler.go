@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"deployment-platform/internal/services/admin"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service admin.Service
+}
+
+func NewHandler(service admin.Service) *Handler {
+	return &Handler{service: service}
+}
+
+type ReplayRequest struct {
+	DeployID string `json:"deploy_id"` // empty replays every dead-lettered job
+}
+
+func (h *Handler) ListDeadLetters(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return
+	}
+
+	letters, err := h.service.ListDeadLetters(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, letters)
+}
+
+func (h *Handler) ReplayDeadLetters(c *gin.Context) {
+	var req ReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	replayed, err := h.service.ReplayDeadLetters(c.Request.Context(), req.DeployID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"replayed": replayed})
+}
+
+func (h *Handler) PurgeDeployment(c *gin.Context) {
+	deployID := c.Param("id")
+
+	err := h.service.PurgeDeployment(c.Request.Context(), deployID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, admin.ErrDeploymentNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Deployment purged"})
+}
@@ -0,0 +1,136 @@
+package alias
+
+import (
+	"errors"
+	"net/http"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/services/alias"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service alias.Service
+}
+
+func NewHandler(service alias.Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) CreateAlias(c *gin.Context) {
+	var req CreateAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	a, err := h.service.CreateAlias(c.Request.Context(), userID, req.Name, req.DeployID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, h.response(a))
+}
+
+func (h *Handler) GetAliases(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	aliases, err := h.service.ListAliases(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := make([]AliasResponse, 0, len(aliases))
+	for i := range aliases {
+		resp = append(resp, h.response(&aliases[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) GetAlias(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	a, err := h.service.GetAlias(c.Request.Context(), userID, c.Param("name"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.response(a))
+}
+
+func (h *Handler) UpdateAlias(c *gin.Context) {
+	var req UpdateAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	a, err := h.service.UpdateAlias(c.Request.Context(), userID, c.Param("name"), req.DeployID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.response(a))
+}
+
+func (h *Handler) DeleteAlias(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	if err := h.service.DeleteAlias(c.Request.Context(), userID, c.Param("name")); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alias deleted successfully"})
+}
+
+func (h *Handler) Rollback(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	a, err := h.service.Rollback(c.Request.Context(), userID, c.Param("name"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.response(a))
+}
+
+func (h *Handler) GetHistory(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	targets, err := h.service.History(c.Request.Context(), userID, c.Param("name"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, targets)
+}
+
+func (h *Handler) response(a *models.Alias) AliasResponse {
+	return AliasResponse{Alias: *a, URL: h.service.AliasURL(a)}
+}
+
+func (h *Handler) writeError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	var verr *alias.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		status = http.StatusBadRequest
+	case errors.Is(err, alias.ErrAliasNotFound), errors.Is(err, alias.ErrDeploymentNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, alias.ErrNameTaken), errors.Is(err, alias.ErrNoPreviousTarget):
+		status = http.StatusConflict
+	case errors.Is(err, alias.ErrNotDeployed), errors.Is(err, alias.ErrWrongProject), errors.Is(err, alias.ErrProjectAlias):
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,17 @@
+package alias
+
+import "deployment-platform/internal/models"
+
+type CreateAliasRequest struct {
+	Name     string `json:"name" binding:"required"`
+	DeployID string `json:"deploy_id" binding:"required"`
+}
+
+type UpdateAliasRequest struct {
+	DeployID string `json:"deploy_id" binding:"required"`
+}
+
+type AliasResponse struct {
+	models.Alias
+	URL string `json:"url"`
+}
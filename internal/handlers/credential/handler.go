@@ -0,0 +1,107 @@
+package credential
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"deployment-platform/internal/services/credential"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service credential.Service
+}
+
+func NewHandler(service credential.Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) CreateCredential(c *gin.Context) {
+	var req CreateCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	cred, err := h.service.CreateCredential(c.Request.Context(), userID, credential.CreateCredentialInput{
+		Name:     req.Name,
+		Type:     req.Type,
+		Username: req.Username,
+		Token:    req.Token,
+	})
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, cred)
+}
+
+func (h *Handler) GetCredentials(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	creds, err := h.service.ListCredentials(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, creds)
+}
+
+func (h *Handler) GetCredential(c *gin.Context) {
+	credentialID, ok := credentialID(c)
+	if !ok {
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	cred, err := h.service.GetCredential(c.Request.Context(), credentialID, userID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cred)
+}
+
+func (h *Handler) DeleteCredential(c *gin.Context) {
+	credentialID, ok := credentialID(c)
+	if !ok {
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	if err := h.service.DeleteCredential(c.Request.Context(), credentialID, userID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Credential deleted successfully"})
+}
+
+func (h *Handler) writeError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	var verr *credential.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		status = http.StatusBadRequest
+	case errors.Is(err, credential.ErrCredentialNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, credential.ErrNameTaken):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+func credentialID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential id"})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,8 @@
+package credential
+
+type CreateCredentialRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Type     string `json:"type" binding:"required,oneof=token ssh"`
+	Username string `json:"username" binding:"max=255"`
+	Token    string `json:"token"`
+}
@@ -1,6 +1,8 @@
 package deployer
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
 	"deployment-platform/internal/services/deployer"
@@ -9,11 +11,12 @@ import (
 )
 
 type Handler struct {
-	service deployer.Service
+	service   deployer.Service
+	maxUpload int64 // bytes accepted by Upload
 }
 
-func NewHandler(service deployer.Service) *Handler {
-	return &Handler{service: service}
+func NewHandler(service deployer.Service, maxUpload int64) *Handler {
+	return &Handler{service: service, maxUpload: maxUpload}
 }
 
 func (h *Handler) Deploy(c *gin.Context) {
@@ -29,9 +32,25 @@ func (h *Handler) Deploy(c *gin.Context) {
 		userID = val.(uint)
 	}
 
-	deployment, err := h.service.CreateDeployment(c.Request.Context(), userID, req.RepoURL)
+	deployment, err := h.service.CreateDeployment(c.Request.Context(), userID, deployer.CreateDeploymentInput{
+		RepoURL:       req.RepoURL,
+		ProjectID:     req.ProjectID,
+		Ref:           req.Ref,
+		CredentialID:  req.CredentialID,
+		RootDirectory: req.RootDirectory,
+		NodeVersion:   req.NodeVersion,
+		NoCache:       req.NoCache,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, deployer.ErrProjectNotFound), errors.Is(err, deployer.ErrCredentialNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, deployer.ErrRepoMismatch), errors.Is(err, deployer.ErrInvalidRef),
+			errors.Is(err, deployer.ErrInvalidNodeVersion), errors.Is(err, deployer.ErrInvalidRootDir):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -44,8 +63,9 @@ func (h *Handler) Deploy(c *gin.Context) {
 
 func (h *Handler) GetStatus(c *gin.Context) {
 	deployID := c.Param("id")
+	userID := c.GetUint("user_id")
 
-	deployment, err := h.service.GetDeploymentStatus(c.Request.Context(), deployID)
+	deployment, err := h.service.GetDeploymentStatus(c.Request.Context(), deployID, userID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Deployment not found"})
 		return
@@ -78,3 +98,105 @@ func (h *Handler) DeleteDeployment(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Deployment deleted successfully"})
 }
+
+func (h *Handler) Redeploy(c *gin.Context) {
+	var req RedeployRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	userID := c.GetUint("user_id")
+
+	deployment, err := h.service.Redeploy(c.Request.Context(), c.Param("id"), userID, req.NoCache)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, deployer.ErrDeploymentNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, deployer.ErrUploadGone):
+			status = http.StatusGone
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":           deployment.DeployID,
+		"status":       deployment.Status,
+		"deployed_url": deployment.DeployedURL,
+	})
+}
+
+// Upload deploys a .tar.gz or .zip archive sent as the raw request body,
+// with options in the query string. The archive streams to object storage,
+// so its size must be declared up front.
+func (h *Handler) Upload(c *gin.Context) {
+	var req UploadRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	size := c.Request.ContentLength
+	switch {
+	case size < 0:
+		c.JSON(http.StatusLengthRequired, gin.H{"error": "Content-Length is required"})
+		return
+	case size == 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "archive is empty"})
+		return
+	case size > h.maxUpload:
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("archive exceeds %d MB", h.maxUpload>>20)})
+		return
+	}
+
+	deployment, err := h.service.CreateUploadDeployment(c.Request.Context(), c.GetUint("user_id"), deployer.UploadDeploymentInput{
+		ProjectID:     req.ProjectID,
+		Target:        req.Target,
+		Prebuilt:      req.Prebuilt,
+		RootDirectory: req.RootDirectory,
+		NodeVersion:   req.NodeVersion,
+		Archive:       http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUpload),
+		Size:          size,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.Is(err, deployer.ErrProjectNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, deployer.ErrUnsupportedArchive), errors.Is(err, deployer.ErrInvalidTarget),
+			errors.Is(err, deployer.ErrInvalidRootDir), errors.Is(err, deployer.ErrInvalidNodeVersion):
+			status = http.StatusBadRequest
+		case errors.As(err, &tooLarge):
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":           deployment.DeployID,
+		"status":       deployment.Status,
+		"deployed_url": deployment.DeployedURL,
+	})
+}
+
+func (h *Handler) CancelDeployment(c *gin.Context) {
+	deployID := c.Param("id")
+	userID := c.GetUint("user_id")
+
+	err := h.service.CancelDeployment(c.Request.Context(), deployID, userID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, deployer.ErrDeploymentFinished) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Deployment cancellation requested"})
+}
@@ -3,7 +3,26 @@ package deployer
 import "time"
 
 type DeployRequest struct {
-	RepoURL string `json:"repo_url" binding:"required,url"`
+	RepoURL       string `json:"repo_url" binding:"required_without=ProjectID,omitempty,url"`
+	ProjectID     *uint  `json:"project_id"`
+	Ref           string `json:"ref"`            // branch, tag or commit SHA, defaults to the default branch
+	CredentialID  *uint  `json:"credential_id"`  // git credential for private repositories
+	RootDirectory string `json:"root_directory"` // directory of a monorepo to build, overrides the project's
+	NodeVersion   string `json:"node_version"`   // overrides .nvmrc, .node-version and engines.node
+	NoCache       bool   `json:"no_cache"`       // skip restoring the dependency cache
+}
+
+// UploadRequest holds the query parameters of POST /deployments/upload.
+type UploadRequest struct {
+	ProjectID     *uint  `form:"project_id"`
+	Target        string `form:"target"`   // production (default) or preview, for project uploads
+	Prebuilt      bool   `form:"prebuilt"` // publish the archive as-is instead of building it
+	RootDirectory string `form:"root_directory"`
+	NodeVersion   string `form:"node_version"`
+}
+
+type RedeployRequest struct {
+	NoCache bool `json:"no_cache"`
 }
 
 type DeploymentResponse struct {
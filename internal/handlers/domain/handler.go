@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"errors"
+	"net/http"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/services/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service domain.Service
+}
+
+func NewHandler(service domain.Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) AddDomain(c *gin.Context) {
+	var req AddDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	d, err := h.service.AddDomain(c.Request.Context(), userID, req.Hostname, domain.Target{
+		DeployID:  req.DeployID,
+		ProjectID: req.ProjectID,
+	})
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, h.response(d))
+}
+
+func (h *Handler) GetDomains(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	domains, err := h.service.ListDomains(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := make([]DomainResponse, 0, len(domains))
+	for i := range domains {
+		resp = append(resp, h.response(&domains[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) GetDomain(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	d, err := h.service.GetDomain(c.Request.Context(), userID, c.Param("hostname"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.response(d))
+}
+
+func (h *Handler) UpdateDomain(c *gin.Context) {
+	var req UpdateDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	d, err := h.service.UpdateDomain(c.Request.Context(), userID, c.Param("hostname"), domain.Target{
+		DeployID:  req.DeployID,
+		ProjectID: req.ProjectID,
+	})
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.response(d))
+}
+
+func (h *Handler) VerifyDomain(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	d, err := h.service.VerifyDomain(c.Request.Context(), userID, c.Param("hostname"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.response(d))
+}
+
+func (h *Handler) RemoveDomain(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	if err := h.service.RemoveDomain(c.Request.Context(), userID, c.Param("hostname")); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Domain removed successfully"})
+}
+
+func (h *Handler) response(d *models.Domain) DomainResponse {
+	return DomainResponse{
+		Domain:       *d,
+		Verified:     d.VerifiedAt != nil,
+		Verification: h.service.Verification(d),
+	}
+}
+
+func (h *Handler) writeError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	var verr *domain.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		status = http.StatusBadRequest
+	case errors.Is(err, domain.ErrDomainNotFound), errors.Is(err, domain.ErrTargetNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, domain.ErrDomainExists), errors.Is(err, domain.ErrDomainClaimed):
+		status = http.StatusConflict
+	case errors.Is(err, domain.ErrVerificationFailed):
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
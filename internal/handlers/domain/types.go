@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/services/domain"
+)
+
+type AddDomainRequest struct {
+	Hostname  string `json:"hostname" binding:"required"`
+	DeployID  string `json:"deploy_id"`
+	ProjectID *uint  `json:"project_id"`
+}
+
+type UpdateDomainRequest struct {
+	DeployID  string `json:"deploy_id"`
+	ProjectID *uint  `json:"project_id"`
+}
+
+type DomainResponse struct {
+	models.Domain
+	Verified     bool                      `json:"verified"`
+	Verification domain.VerificationRecord `json:"verification"`
+}
@@ -0,0 +1,171 @@
+package envvar
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/services/envvar"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service envvar.Service
+}
+
+func NewHandler(service envvar.Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) CreateEnvVar(c *gin.Context) {
+	var req CreateEnvVarRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	v, err := h.service.CreateEnvVar(c.Request.Context(), userID, envvar.CreateEnvVarInput{
+		RepoURL: req.RepoURL,
+		Key:     req.Key,
+		Values:  envvar.Values{Production: req.Production, Preview: req.Preview},
+		Secret:  req.Secret,
+	})
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	h.respond(c, http.StatusCreated, v)
+}
+
+func (h *Handler) GetEnvVars(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	envVars, err := h.service.ListEnvVars(c.Request.Context(), userID, c.Query("repo_url"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := make([]EnvVarResponse, 0, len(envVars))
+	for i := range envVars {
+		r, err := h.response(&envVars[i])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		resp = append(resp, r)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) GetEnvVar(c *gin.Context) {
+	envVarID, ok := envVarID(c)
+	if !ok {
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	v, err := h.service.GetEnvVar(c.Request.Context(), envVarID, userID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	h.respond(c, http.StatusOK, v)
+}
+
+func (h *Handler) UpdateEnvVar(c *gin.Context) {
+	envVarID, ok := envVarID(c)
+	if !ok {
+		return
+	}
+	var req UpdateEnvVarRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	v, err := h.service.UpdateEnvVar(c.Request.Context(), envVarID, userID, envvar.UpdateEnvVarInput{
+		Values: envvar.Values{Production: req.Production, Preview: req.Preview},
+		Secret: req.Secret,
+	})
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	h.respond(c, http.StatusOK, v)
+}
+
+func (h *Handler) DeleteEnvVar(c *gin.Context) {
+	envVarID, ok := envVarID(c)
+	if !ok {
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	if err := h.service.DeleteEnvVar(c.Request.Context(), envVarID, userID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Environment variable deleted successfully"})
+}
+
+func (h *Handler) respond(c *gin.Context, status int, v *models.EnvVar) {
+	resp, err := h.response(v)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, resp)
+}
+
+func (h *Handler) response(v *models.EnvVar) (EnvVarResponse, error) {
+	values, err := h.service.Reveal(v)
+	if err != nil {
+		return EnvVarResponse{}, err
+	}
+
+	targets := []string{}
+	if v.ProductionValue != "" {
+		targets = append(targets, models.TargetProduction)
+	}
+	if v.PreviewValue != "" {
+		targets = append(targets, models.TargetPreview)
+	}
+	return EnvVarResponse{
+		EnvVar:     *v,
+		Production: values.Production,
+		Preview:    values.Preview,
+		Targets:    targets,
+	}, nil
+}
+
+func (h *Handler) writeError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	var verr *envvar.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		status = http.StatusBadRequest
+	case errors.Is(err, envvar.ErrEnvVarNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, envvar.ErrEnvVarExists):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+func envVarID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid environment variable id"})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,26 @@
+package envvar
+
+import "deployment-platform/internal/models"
+
+type CreateEnvVarRequest struct {
+	RepoURL    string  `json:"repo_url" binding:"required,url"`
+	Key        string  `json:"key" binding:"required,max=255"`
+	Production *string `json:"production"`
+	Preview    *string `json:"preview"`
+	Secret     bool    `json:"secret"`
+}
+
+type UpdateEnvVarRequest struct {
+	Production *string `json:"production"` // "" unsets the production value
+	Preview    *string `json:"preview"`    // "" unsets the preview value
+	Secret     *bool   `json:"secret"`
+}
+
+// EnvVarResponse carries the values of plain variables; secret variables
+// only list the targets they are set for.
+type EnvVarResponse struct {
+	models.EnvVar
+	Production *string  `json:"production,omitempty"`
+	Preview    *string  `json:"preview,omitempty"`
+	Targets    []string `json:"targets"`
+}
@@ -0,0 +1,187 @@
+package project
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/services/project"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service project.Service
+}
+
+func NewHandler(service project.Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) CreateProject(c *gin.Context) {
+	var req CreateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	p, err := h.service.CreateProject(c.Request.Context(), userID, project.CreateProjectInput{
+		Name:          req.Name,
+		Slug:          req.Slug,
+		RepoURL:       req.RepoURL,
+		DefaultBranch: req.DefaultBranch,
+		CredentialID:  req.CredentialID,
+		BuildSettings: req.BuildSettings,
+	})
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, h.response(p))
+}
+
+func (h *Handler) GetProjects(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	projects, err := h.service.ListProjects(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := make([]ProjectResponse, 0, len(projects))
+	for i := range projects {
+		resp = append(resp, h.response(&projects[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) GetProject(c *gin.Context) {
+	projectID, ok := projectID(c)
+	if !ok {
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	p, err := h.service.GetProject(c.Request.Context(), projectID, userID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.response(p))
+}
+
+func (h *Handler) UpdateProject(c *gin.Context) {
+	projectID, ok := projectID(c)
+	if !ok {
+		return
+	}
+	var req UpdateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	p, err := h.service.UpdateProject(c.Request.Context(), projectID, userID, project.UpdateProjectInput{
+		Name:          req.Name,
+		Slug:          req.Slug,
+		RepoURL:       req.RepoURL,
+		DefaultBranch: req.DefaultBranch,
+		CredentialID:  req.CredentialID,
+		BuildSettings: req.BuildSettings,
+	})
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.response(p))
+}
+
+func (h *Handler) DeleteProject(c *gin.Context) {
+	projectID, ok := projectID(c)
+	if !ok {
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	if err := h.service.DeleteProject(c.Request.Context(), projectID, userID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project deleted successfully"})
+}
+
+func (h *Handler) GetDeployments(c *gin.Context) {
+	projectID, ok := projectID(c)
+	if !ok {
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	deployments, err := h.service.ListDeployments(c.Request.Context(), projectID, userID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deployments)
+}
+
+// Webhook returns the secret to configure on the git host. Only POST
+// changes it, by rotating it.
+func (h *Handler) Webhook(c *gin.Context) {
+	projectID, ok := projectID(c)
+	if !ok {
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	secret, err := h.service.WebhookSecret(c.Request.Context(), projectID, userID, c.Request.Method == http.MethodPost)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WebhookResponse{
+		Secret: secret,
+		URLs: map[string]string{
+			"github": "/webhooks/github",
+			"gitlab": "/webhooks/gitlab",
+			"gitea":  "/webhooks/gitea",
+		},
+	})
+}
+
+func (h *Handler) response(p *models.Project) ProjectResponse {
+	return ProjectResponse{Project: *p, URL: h.service.ProjectURL(p)}
+}
+
+func (h *Handler) writeError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	var verr *project.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		status = http.StatusBadRequest
+	case errors.Is(err, project.ErrProjectNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, project.ErrSlugTaken):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+func projectID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project id"})
+		return 0, false
+	}
+	return uint(id), true
+}
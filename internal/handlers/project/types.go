@@ -0,0 +1,31 @@
+package project
+
+import "deployment-platform/internal/models"
+
+type CreateProjectRequest struct {
+	Name          string                `json:"name" binding:"required,max=100"`
+	Slug          string                `json:"slug"`
+	RepoURL       string                `json:"repo_url" binding:"required,url"`
+	DefaultBranch string                `json:"default_branch" binding:"omitempty,max=255"`
+	CredentialID  *uint                 `json:"credential_id"`
+	BuildSettings *models.ProjectConfig `json:"build_settings"`
+}
+
+type UpdateProjectRequest struct {
+	Name          *string               `json:"name" binding:"omitempty,min=1,max=100"`
+	Slug          *string               `json:"slug"`
+	RepoURL       *string               `json:"repo_url" binding:"omitempty,url"`
+	DefaultBranch *string               `json:"default_branch" binding:"omitempty,min=1,max=255"`
+	CredentialID  *uint                 `json:"credential_id"` // 0 detaches the credential
+	BuildSettings *models.ProjectConfig `json:"build_settings"`
+}
+
+type WebhookResponse struct {
+	Secret string            `json:"secret"`
+	URLs   map[string]string `json:"urls"`
+}
+
+type ProjectResponse struct {
+	models.Project
+	URL string `json:"url"`
+}
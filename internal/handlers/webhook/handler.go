@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"deployment-platform/internal/services/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPayloadSize bounds webhook bodies; GitHub caps them at 25MB.
+const maxPayloadSize = 25 << 20
+
+type Handler struct {
+	service webhook.Service
+}
+
+func NewHandler(service webhook.Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) Receive(c *gin.Context) {
+	delivery := webhook.Delivery{Provider: c.Param("provider")}
+	switch delivery.Provider {
+	case webhook.ProviderGitHub:
+		delivery.Event = c.GetHeader("X-GitHub-Event")
+		delivery.Signature = c.GetHeader("X-Hub-Signature-256")
+	case webhook.ProviderGitLab:
+		delivery.Event = c.GetHeader("X-Gitlab-Event")
+		delivery.Signature = c.GetHeader("X-Gitlab-Token")
+	case webhook.ProviderGitea:
+		delivery.Event = c.GetHeader("X-Gitea-Event")
+		delivery.Signature = c.GetHeader("X-Gitea-Signature")
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": webhook.ErrUnknownProvider.Error()})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPayloadSize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Payload too large"})
+		return
+	}
+	delivery.Body = body
+
+	result, err := h.service.HandleDelivery(c.Request.Context(), delivery)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, webhook.ErrInvalidPayload):
+			status = http.StatusBadRequest
+		case errors.Is(err, webhook.ErrInvalidSignature):
+			status = http.StatusUnauthorized
+		case errors.Is(err, webhook.ErrNoProject):
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if result.Ignored != "" {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	c.JSON(http.StatusAccepted, result)
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 
+	"deployment-platform/internal/services/deployer"
 	"deployment-platform/internal/services/websocket"
 
 	"github.com/gin-gonic/gin"
@@ -19,11 +20,12 @@ var upgrader = ws.Upgrader{
 }
 
 type Handler struct {
-	hub *websocket.Hub
+	hub      *websocket.Hub
+	deployer deployer.Service
 }
 
-func NewHandler(hub *websocket.Hub) *Handler {
-	return &Handler{hub: hub}
+func NewHandler(hub *websocket.Hub, depService deployer.Service) *Handler {
+	return &Handler{hub: hub, deployer: depService}
 }
 
 func (h *Handler) HandleLogs(c *gin.Context) {
@@ -33,6 +35,13 @@ func (h *Handler) HandleLogs(c *gin.Context) {
 		return
 	}
 
+	// Only the owner may follow a deployment's logs
+	userID := c.GetUint("user_id")
+	if _, err := h.deployer.GetDeploymentStatus(c.Request.Context(), deploymentID, userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deployment not found"})
+		return
+	}
+
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("Failed to upgrade connection: %v", err)
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Alias maps a stable hostname to one deployment. Repointing it promotes a
+// deployment without rebuilding; its targets are kept for rollback.
+type Alias struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	UserID    uint           `gorm:"index;not null" json:"user_id"`
+	ProjectID *uint          `gorm:"index" json:"project_id,omitempty"` // set for the production alias a project manages
+	Name      string         `gorm:"not null" json:"name"`
+	Hostname  string         `gorm:"uniqueIndex:idx_aliases_hostname,where:deleted_at IS NULL;not null" json:"hostname"`
+	DeployID  string         `json:"deploy_id"` // empty until a project's first production deployment succeeds
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// AliasTarget records each deployment an alias pointed at. Rolling back
+// marks the current target and returns to the one before it.
+type AliasTarget struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	AliasID      uint       `gorm:"index;not null" json:"alias_id"`
+	DeployID     string     `gorm:"not null" json:"deploy_id"`
+	RolledBackAt *time.Time `json:"rolled_back_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Certificate holds ACME certificates, account keys and pending HTTP-01
+// tokens so every request handler replica shares them.
+type Certificate struct {
+	Key       string    `gorm:"primarykey" json:"key"`
+	Data      []byte    `gorm:"not null" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
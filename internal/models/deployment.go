@@ -6,18 +6,52 @@ import (
 	"gorm.io/gorm"
 )
 
+// Deployment targets. Production deployments of a project are promoted to
+// its stable URL once they succeed; previews only get their own URL.
+const (
+	TargetProduction = "production"
+	TargetPreview    = "preview"
+)
+
+// Deployment sources: a git repository cloned by the worker, or an archive
+// uploaded through the API.
+const (
+	SourceGit    = "git"
+	SourceUpload = "upload"
+)
+
 type Deployment struct {
-	ID          uint           `gorm:"primarykey" json:"id"`
-	UserID      uint           `json:"user_id"`
-	DeployID    string         `gorm:"uniqueIndex;not null" json:"deploy_id"`
-	RepoURL     string         `gorm:"not null" json:"repo_url"`
-	Status      string         `gorm:"default:'pending'" json:"status"` // pending, cloning, uploading, building, deployed, failed
-	DeployedURL string         `json:"deployed_url,omitempty"`
-	BuildLog    string         `gorm:"type:text" json:"build_log,omitempty"`
-	ErrorMsg    string         `gorm:"type:text" json:"error_msg,omitempty"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                    uint           `gorm:"primarykey" json:"id"`
+	UserID                uint           `json:"user_id"`
+	ProjectID             *uint          `gorm:"index" json:"project_id,omitempty"`
+	Target                string         `json:"target,omitempty"` // production or preview, empty for standalone deployments
+	DeployID              string         `gorm:"uniqueIndex;not null" json:"deploy_id"`
+	Source                string         `json:"source,omitempty"`   // git, or upload for archives sent to POST /deployments/upload
+	Prebuilt              bool           `json:"prebuilt,omitempty"` // uploaded output published without a build
+	RepoURL               string         `gorm:"not null" json:"repo_url"`
+	Branch                string         `json:"branch,omitempty"`
+	Ref                   string         `json:"ref,omitempty"` // branch, tag or commit requested, empty for the default branch
+	CommitSHA             string         `json:"commit_sha,omitempty"`
+	CredentialID          *uint          `json:"credential_id,omitempty"`
+	RootDirectory         string         `json:"root_directory,omitempty"` // requested, then the directory the build ran in
+	NodeVersion           string         `json:"node_version,omitempty"`   // requested, then the Node.js major the build used
+	NoCache               bool           `json:"no_cache,omitempty"`       // build without restoring the dependency cache
+	CommitMessage         string         `gorm:"type:text" json:"commit_message,omitempty"`
+	CommitAuthor          string         `json:"commit_author,omitempty"`
+	Status                string         `gorm:"default:'pending'" json:"status"` // pending, cloning, extracting, uploading, building, retrying, deployed, skipped, failed, cancelled
+	DeployedURL           string         `json:"deployed_url,omitempty"`
+	UnchangedFrom         string         `json:"unchanged_from,omitempty"` // for skipped deployments, the deployment still serving the unchanged source
+	Framework             string         `json:"framework,omitempty"`
+	PackageManager        string         `json:"package_manager,omitempty"`         // npm, yarn, pnpm or bun
+	PackageManagerVersion string         `json:"package_manager_version,omitempty"` // as reported by the build
+	Config                *ProjectConfig `gorm:"type:jsonb;serializer:json" json:"config,omitempty"`
+	BuildLog              string         `gorm:"type:text" json:"build_log,omitempty"`
+	ErrorMsg              string         `gorm:"type:text" json:"error_msg,omitempty"`
+	Attempts              int            `gorm:"default:0" json:"attempts"`
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	DeletedAt             gorm.DeletedAt `gorm:"index" json:"-"`
 
-	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	User    User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Project *Project `gorm:"foreignKey:ProjectID" json:"-"`
 }
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Domain attaches a user's own hostname to a deployment, or to a project so
+// it follows the project's production alias. It is only routed once DNS
+// ownership has been verified through a TXT record.
+type Domain struct {
+	ID         uint           `gorm:"primarykey" json:"id"`
+	UserID     uint           `gorm:"not null;uniqueIndex:idx_domains_user_hostname,where:deleted_at IS NULL" json:"user_id"`
+	Hostname   string         `gorm:"not null;uniqueIndex:idx_domains_user_hostname;uniqueIndex:idx_domains_verified,where:verified_at IS NOT NULL AND deleted_at IS NULL" json:"hostname"`
+	DeployID   string         `json:"deploy_id,omitempty"`
+	ProjectID  *uint          `gorm:"index" json:"project_id,omitempty"`
+	Token      string         `gorm:"not null" json:"-"`
+	VerifiedAt *time.Time     `json:"verified_at,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EnvVar is an environment variable passed to the builds of one of a user's
+// repositories. Production and preview deployments get separate values;
+// either may be unset. Values are sealed with the platform's secrets key and
+// secret values are never returned by the API and masked in build logs.
+type EnvVar struct {
+	ID              uint           `gorm:"primarykey" json:"id"`
+	UserID          uint           `gorm:"not null;uniqueIndex:idx_env_vars_repo_key,where:deleted_at IS NULL" json:"user_id"`
+	RepoKey         string         `gorm:"not null;uniqueIndex:idx_env_vars_repo_key" json:"-"` // normalised RepoURL
+	RepoURL         string         `gorm:"not null" json:"repo_url"`
+	Key             string         `gorm:"not null;uniqueIndex:idx_env_vars_repo_key" json:"key"`
+	ProductionValue string         `gorm:"type:text" json:"-"` // sealed, empty when unset
+	PreviewValue    string         `gorm:"type:text" json:"-"` // sealed, empty when unset
+	Secret          bool           `gorm:"default:false" json:"secret"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+}
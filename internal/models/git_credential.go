@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Git credential types.
+const (
+	CredentialToken = "token" // HTTPS personal access token
+	CredentialSSH   = "ssh"   // SSH deploy key generated by the platform
+)
+
+// GitCredential lets the worker clone private repositories. Secret holds the
+// token or the SSH private key, sealed with the platform's secrets key; it
+// is never returned by the API.
+type GitCredential struct {
+	ID          uint           `gorm:"primarykey" json:"id"`
+	UserID      uint           `gorm:"not null;uniqueIndex:idx_git_credentials_user_name,where:deleted_at IS NULL" json:"user_id"`
+	Name        string         `gorm:"not null;uniqueIndex:idx_git_credentials_user_name" json:"name"`
+	Type        string         `gorm:"not null" json:"type"`
+	Username    string         `json:"username,omitempty"` // HTTPS username, or the SSH user (default "git")
+	Secret      string         `gorm:"type:text;not null" json:"-"`
+	PublicKey   string         `gorm:"type:text" json:"public_key,omitempty"`
+	Fingerprint string         `json:"fingerprint,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// Job is a queued unit of work for the Postgres queue backend.
+type Job struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	Queue     string     `gorm:"not null;index:idx_jobs_poll,priority:1" json:"queue"`
+	Status    string     `gorm:"not null;index:idx_jobs_poll,priority:2" json:"status"` // queued, running, dead
+	RunAt     time.Time  `gorm:"not null;index:idx_jobs_poll,priority:3" json:"run_at"`
+	Body      []byte     `gorm:"not null" json:"-"`
+	LockedAt  *time.Time `json:"locked_at,omitempty"`
+	FailedAt  *time.Time `json:"failed_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
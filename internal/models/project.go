@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Project groups the deployments of one site. Its slug names the alias
+// that serves the current production deployment.
+type Project struct {
+	ID            uint           `gorm:"primarykey" json:"id"`
+	UserID        uint           `gorm:"index;not null" json:"user_id"`
+	Name          string         `gorm:"not null" json:"name"`
+	Slug          string         `gorm:"uniqueIndex:idx_projects_slug,where:deleted_at IS NULL;not null" json:"slug"`
+	RepoURL       string         `gorm:"not null" json:"repo_url"`
+	RepoKey       string         `gorm:"index" json:"-"` // normalised RepoURL, matched against push webhooks
+	DefaultBranch string         `gorm:"default:'main'" json:"default_branch"`
+	CredentialID  *uint          `json:"credential_id,omitempty"` // git credential used to clone RepoURL
+	BuildSettings *ProjectConfig `gorm:"type:jsonb;serializer:json" json:"build_settings,omitempty"`
+	WebhookSecret string         `json:"-"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
@@ -0,0 +1,36 @@
+package models
+
+// ProjectConfig mirrors the gopher.json file committed at a repository root.
+// Fields left empty fall back to the values chosen by framework detection.
+type ProjectConfig struct {
+	InstallCommand  *string        `json:"installCommand,omitempty" validate:"omitempty,max=1024"`
+	BuildCommand    *string        `json:"buildCommand,omitempty" validate:"omitempty,max=1024"`
+	OutputDirectory string         `json:"outputDirectory,omitempty" validate:"omitempty,relpath"`
+	RootDirectory   string         `json:"rootDirectory,omitempty" validate:"omitempty,relpath"`
+	NodeVersion     string         `json:"nodeVersion,omitempty" validate:"omitempty,max=32"`
+	Headers         []HeaderRule   `json:"headers,omitempty" validate:"omitempty,max=100,dive"`
+	Redirects       []RedirectRule `json:"redirects,omitempty" validate:"omitempty,max=1000,dive"`
+	Rewrites        []RewriteRule  `json:"rewrites,omitempty" validate:"omitempty,max=1000,dive"`
+}
+
+type HeaderRule struct {
+	Source  string   `json:"source" validate:"required,startswith=/"`
+	Headers []Header `json:"headers" validate:"required,min=1,dive"`
+}
+
+type Header struct {
+	Key   string `json:"key" validate:"required,printascii,excludesall= :"`
+	Value string `json:"value" validate:"required"`
+}
+
+type RedirectRule struct {
+	Source      string `json:"source" validate:"required,startswith=/"`
+	Destination string `json:"destination" validate:"required"`
+	Permanent   bool   `json:"permanent,omitempty"`
+	StatusCode  int    `json:"statusCode,omitempty" validate:"omitempty,oneof=301 302 303 307 308"`
+}
+
+type RewriteRule struct {
+	Source      string `json:"source" validate:"required,startswith=/"`
+	Destination string `json:"destination" validate:"required,startswith=/"`
+}
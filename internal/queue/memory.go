@@ -0,0 +1,233 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryQueue keeps jobs in process memory. Jobs are lost on restart and are
+// only visible to consumers in the same process, so it suits development
+// with the in-process worker and hermetic tests.
+type MemoryQueue struct {
+	retryDelays []time.Duration
+
+	mu      sync.Mutex
+	pending map[string][]memoryMessage
+	dead    map[string][]memoryMessage
+	notify  map[string]chan struct{} // closed and replaced when a queue gains jobs
+	closed  bool
+}
+
+type memoryMessage struct {
+	body     []byte
+	failedAt time.Time
+}
+
+// memoryDelivery ties a job to its queue and frees the consumer's prefetch slot once settled.
+type memoryDelivery struct {
+	queueName string
+	body      []byte
+	release   func()
+	once      *sync.Once
+}
+
+func NewMemoryQueue(retryDelays []time.Duration) *MemoryQueue {
+	return &MemoryQueue{
+		retryDelays: retryDelays,
+		pending:     make(map[string][]memoryMessage),
+		dead:        make(map[string][]memoryMessage),
+		notify:      make(map[string]chan struct{}),
+	}
+}
+
+func (q *MemoryQueue) Publish(ctx context.Context, queueName string, body []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	q.pending[queueName] = append(q.pending[queueName], memoryMessage{body: body})
+	q.signal(queueName)
+	return nil
+}
+
+// signal wakes consumers of queueName; callers hold q.mu.
+func (q *MemoryQueue) signal(queueName string) {
+	if ch, ok := q.notify[queueName]; ok {
+		close(ch)
+	}
+	q.notify[queueName] = make(chan struct{})
+}
+
+func (q *MemoryQueue) waitChan(queueName string) <-chan struct{} {
+	ch, ok := q.notify[queueName]
+	if !ok {
+		ch = make(chan struct{})
+		q.notify[queueName] = ch
+	}
+	return ch
+}
+
+func (q *MemoryQueue) Consume(ctx context.Context, queueName string, prefetch int) (<-chan *Job, error) {
+	slots := make(chan struct{}, max(prefetch, 1))
+	out := make(chan *Job)
+
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			job, ok := q.next(ctx, queueName)
+			if !ok {
+				return
+			}
+			job.handle.(*memoryDelivery).release = func() { <-slots }
+
+			// The job left the queue; hand it over even if ctx is cancelled
+			// now so the consumer can settle it
+			out <- job
+		}
+	}()
+
+	return out, nil
+}
+
+// next blocks until a job is available, ctx is cancelled or the queue closes.
+func (q *MemoryQueue) next(ctx context.Context, queueName string) (*Job, bool) {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return nil, false
+		}
+		if msgs := q.pending[queueName]; len(msgs) > 0 {
+			msg := msgs[0]
+			q.pending[queueName] = msgs[1:]
+			q.mu.Unlock()
+			return &Job{Body: msg.body, handle: &memoryDelivery{
+				queueName: queueName,
+				body:      msg.body,
+				once:      &sync.Once{},
+			}}, true
+		}
+		wait := q.waitChan(queueName)
+		q.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+// settle frees the prefetch slot the first time a job is acked, nacked or retried.
+func (q *MemoryQueue) settle(job *Job) (*memoryDelivery, bool) {
+	d := job.handle.(*memoryDelivery)
+	first := false
+	d.once.Do(func() {
+		first = true
+		if d.release != nil {
+			d.release()
+		}
+	})
+	return d, first
+}
+
+func (q *MemoryQueue) Ack(job *Job) error {
+	q.settle(job)
+	return nil
+}
+
+func (q *MemoryQueue) Nack(job *Job, requeue bool) error {
+	d, first := q.settle(job)
+	if !first {
+		return nil
+	}
+
+	if requeue {
+		return q.Publish(context.Background(), d.queueName, d.body)
+	}
+
+	q.mu.Lock()
+	q.dead[d.queueName] = append(q.dead[d.queueName], memoryMessage{body: d.body, failedAt: time.Now()})
+	q.mu.Unlock()
+	return nil
+}
+
+func (q *MemoryQueue) Retry(job *Job, body []byte, retry int) (time.Duration, error) {
+	d, _ := q.settle(job)
+	_, delay := retryDelay(q.retryDelays, retry)
+
+	time.AfterFunc(delay, func() {
+		q.Publish(context.Background(), d.queueName, body)
+	})
+	return delay, nil
+}
+
+func (q *MemoryQueue) DeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var letters []DeadLetter
+	for _, msg := range q.dead[queueName] {
+		if limit > 0 && len(letters) >= limit {
+			break
+		}
+		letters = append(letters, DeadLetter{Body: msg.body, Reason: "rejected", Count: 1, FailedAt: msg.failedAt})
+	}
+	return letters, nil
+}
+
+func (q *MemoryQueue) ReplayDeadLetters(queueName string, rewrite func(body []byte) ([]byte, bool)) (int, error) {
+	q.mu.Lock()
+	dead := q.dead[queueName]
+	q.dead[queueName] = nil
+	q.mu.Unlock()
+
+	replayed := 0
+	var kept []memoryMessage
+	for _, msg := range dead {
+		body, ok := rewrite(msg.body)
+		if !ok {
+			kept = append(kept, msg)
+			continue
+		}
+		if err := q.Publish(context.Background(), queueName, body); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+
+	q.mu.Lock()
+	q.dead[queueName] = append(kept, q.dead[queueName]...)
+	q.mu.Unlock()
+
+	return replayed, nil
+}
+
+func (q *MemoryQueue) Ping(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	return nil
+}
+
+func (q *MemoryQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	for name := range q.notify {
+		q.signal(name)
+	}
+}
@@ -0,0 +1,238 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+const testQueue = "test_jobs"
+
+func receive(t *testing.T, jobs <-chan *Job) *Job {
+	t.Helper()
+	select {
+	case job, ok := <-jobs:
+		if !ok {
+			t.Fatal("consumer channel closed")
+		}
+		return job
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a job")
+		return nil
+	}
+}
+
+func expectNothing(t *testing.T, jobs <-chan *Job, wait time.Duration) {
+	t.Helper()
+	select {
+	case job := <-jobs:
+		t.Fatalf("unexpected delivery %q", job.Body)
+	case <-time.After(wait):
+	}
+}
+
+func consume(t *testing.T, q JobQueue, prefetch int) <-chan *Job {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	jobs, err := q.Consume(ctx, testQueue, prefetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jobs
+}
+
+func TestMemoryQueueAckFreesPrefetchSlot(t *testing.T) {
+	q := NewMemoryQueue(nil)
+	defer q.Close()
+
+	q.Publish(context.Background(), testQueue, []byte("first"))
+	q.Publish(context.Background(), testQueue, []byte("second"))
+	jobs := consume(t, q, 1)
+
+	first := receive(t, jobs)
+	if string(first.Body) != "first" {
+		t.Fatalf("got %q, want first", first.Body)
+	}
+	expectNothing(t, jobs, 50*time.Millisecond)
+
+	q.Ack(first)
+	if second := receive(t, jobs); string(second.Body) != "second" {
+		t.Fatalf("got %q, want second", second.Body)
+	}
+}
+
+func TestMemoryQueueNackRequeue(t *testing.T) {
+	q := NewMemoryQueue(nil)
+	defer q.Close()
+
+	q.Publish(context.Background(), testQueue, []byte("job"))
+	jobs := consume(t, q, 1)
+
+	q.Nack(receive(t, jobs), true)
+	job := receive(t, jobs)
+	if string(job.Body) != "job" {
+		t.Fatalf("got %q after requeue", job.Body)
+	}
+	q.Ack(job)
+
+	letters, _ := q.DeadLetters(testQueue, 0)
+	if len(letters) != 0 {
+		t.Fatalf("requeued job was dead-lettered: %v", letters)
+	}
+}
+
+func TestMemoryQueueNackDeadLettersAndReplay(t *testing.T) {
+	q := NewMemoryQueue(nil)
+	defer q.Close()
+
+	q.Publish(context.Background(), testQueue, []byte("keep"))
+	q.Publish(context.Background(), testQueue, []byte("replay"))
+	jobs := consume(t, q, 2)
+	q.Nack(receive(t, jobs), false)
+	q.Nack(receive(t, jobs), false)
+	expectNothing(t, jobs, 50*time.Millisecond)
+
+	letters, err := q.DeadLetters(testQueue, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 2 || letters[0].Count != 1 || letters[0].FailedAt.IsZero() {
+		t.Fatalf("unexpected dead letters: %+v", letters)
+	}
+	if limited, _ := q.DeadLetters(testQueue, 1); len(limited) != 1 {
+		t.Fatalf("limit ignored: got %d letters", len(limited))
+	}
+
+	replayed, err := q.ReplayDeadLetters(testQueue, func(body []byte) ([]byte, bool) {
+		if string(body) == "keep" {
+			return nil, false
+		}
+		return []byte("replayed"), true
+	})
+	if err != nil || replayed != 1 {
+		t.Fatalf("replayed %d, %v; want 1", replayed, err)
+	}
+	if job := receive(t, jobs); string(job.Body) != "replayed" {
+		t.Fatalf("got %q, want the rewritten body", job.Body)
+	}
+
+	letters, _ = q.DeadLetters(testQueue, 0)
+	if len(letters) != 1 || string(letters[0].Body) != "keep" {
+		t.Fatalf("skipped dead letter was not kept: %+v", letters)
+	}
+}
+
+func TestMemoryQueueRetryWaitsForDelay(t *testing.T) {
+	delays := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}
+	q := NewMemoryQueue(delays)
+	defer q.Close()
+
+	q.Publish(context.Background(), testQueue, []byte("attempt 1"))
+	jobs := consume(t, q, 1)
+
+	start := time.Now()
+	delay, err := q.Retry(receive(t, jobs), []byte("attempt 2"), 1)
+	if err != nil || delay != delays[0] {
+		t.Fatalf("Retry = %v, %v; want %v", delay, err, delays[0])
+	}
+
+	job := receive(t, jobs)
+	if elapsed := time.Since(start); elapsed < delays[0] {
+		t.Errorf("redelivered after %v, before the %v delay", elapsed, delays[0])
+	}
+	if string(job.Body) != "attempt 2" {
+		t.Errorf("got %q, want the retry body", job.Body)
+	}
+}
+
+func TestMemoryQueueSettlesOnce(t *testing.T) {
+	q := NewMemoryQueue(nil)
+	defer q.Close()
+
+	q.Publish(context.Background(), testQueue, []byte("job"))
+	jobs := consume(t, q, 1)
+
+	job := receive(t, jobs)
+	q.Ack(job)
+	q.Nack(job, false)
+	q.Nack(job, true)
+
+	if letters, _ := q.DeadLetters(testQueue, 0); len(letters) != 0 {
+		t.Errorf("acked job was dead-lettered: %+v", letters)
+	}
+	expectNothing(t, jobs, 50*time.Millisecond)
+}
+
+// The memory queue has no visibility timeout: a job that was never handed
+// out stays queued when its consumer stops and goes to the next consumer.
+func TestMemoryQueueStoppedConsumerLeavesJobsQueued(t *testing.T) {
+	q := NewMemoryQueue(nil)
+	defer q.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped, _ := q.Consume(ctx, testQueue, 1)
+	cancel()
+	for range stopped {
+	}
+
+	q.Publish(context.Background(), testQueue, []byte("job"))
+	if job := receive(t, consume(t, q, 1)); string(job.Body) != "job" {
+		t.Fatalf("got %q", job.Body)
+	}
+}
+
+func TestMemoryQueueClose(t *testing.T) {
+	q := NewMemoryQueue(nil)
+	jobs := consume(t, q, 1)
+	q.Close()
+
+	select {
+	case _, ok := <-jobs:
+		if ok {
+			t.Fatal("delivery after close")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("consumer channel not closed")
+	}
+	if err := q.Publish(context.Background(), testQueue, nil); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Publish after close = %v", err)
+	}
+	if err := q.Ping(context.Background()); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Ping after close = %v", err)
+	}
+}
+
+func TestRetryDelays(t *testing.T) {
+	delays := RetryDelays(time.Second, 4)
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+	if len(delays) != len(want) {
+		t.Fatalf("RetryDelays = %v, want %v", delays, want)
+	}
+	for i := range want {
+		if delays[i] != want[i] {
+			t.Fatalf("RetryDelays = %v, want %v", delays, want)
+		}
+	}
+
+	tests := []struct {
+		retry     int
+		wantStep  int
+		wantDelay time.Duration
+	}{
+		{0, 1, time.Second},
+		{1, 1, time.Second},
+		{3, 3, 4 * time.Second},
+		{9, 3, 4 * time.Second},
+	}
+	for _, tt := range tests {
+		step, delay := retryDelay(delays, tt.retry)
+		if step != tt.wantStep || delay != tt.wantDelay {
+			t.Errorf("retryDelay(%d) = %d, %v; want %d, %v", tt.retry, step, delay, tt.wantStep, tt.wantDelay)
+		}
+	}
+	if step, delay := retryDelay(nil, 1); step != 0 || delay != 0 {
+		t.Errorf("retryDelay with no schedule = %d, %v", step, delay)
+	}
+}
@@ -0,0 +1,254 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"deployment-platform/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDead    = "dead"
+
+	pollInterval = time.Second
+)
+
+// PostgresQueue stores jobs in the jobs table and claims them with
+// SELECT ... FOR UPDATE SKIP LOCKED, so several workers can share it
+// without a message broker. Claimed jobs are kept alive by a heartbeat and
+// returned to the queue if their worker stops updating it.
+type PostgresQueue struct {
+	db                *gorm.DB
+	retryDelays       []time.Duration
+	visibilityTimeout time.Duration
+}
+
+type postgresDelivery struct {
+	id      uint
+	release func()
+	stop    chan struct{}
+	once    sync.Once
+}
+
+func NewPostgresQueue(db *gorm.DB, retryDelays []time.Duration, visibilityTimeout time.Duration) *PostgresQueue {
+	return &PostgresQueue{
+		db:                db,
+		retryDelays:       retryDelays,
+		visibilityTimeout: visibilityTimeout,
+	}
+}
+
+func (q *PostgresQueue) Publish(ctx context.Context, queueName string, body []byte) error {
+	return q.db.WithContext(ctx).Create(&models.Job{
+		Queue:  queueName,
+		Status: jobQueued,
+		RunAt:  time.Now(),
+		Body:   body,
+	}).Error
+}
+
+func (q *PostgresQueue) Consume(ctx context.Context, queueName string, prefetch int) (<-chan *Job, error) {
+	slots := make(chan struct{}, max(prefetch, 1))
+	out := make(chan *Job)
+
+	go func() {
+		defer close(out)
+		lastReclaim := time.Time{}
+
+		for {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			for {
+				if time.Since(lastReclaim) > q.visibilityTimeout/2 {
+					q.reclaim(queueName)
+					lastReclaim = time.Now()
+				}
+
+				job, err := q.claim(queueName)
+				if err == nil {
+					d := job.handle.(*postgresDelivery)
+					d.release = func() { <-slots }
+					go q.heartbeat(d)
+					out <- job
+					break
+				}
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					log.Printf("Failed to claim job from %s: %v", queueName, err)
+				}
+
+				select {
+				case <-time.After(pollInterval):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (q *PostgresQueue) claim(queueName string) (*Job, error) {
+	var row models.Job
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("queue = ? AND status = ? AND run_at <= ?", queueName, jobQueued, time.Now()).
+			Order("run_at, id").
+			First(&row).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&row).Updates(map[string]interface{}{
+			"status":    jobRunning,
+			"locked_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Job{Body: row.Body, handle: &postgresDelivery{id: row.ID, stop: make(chan struct{})}}, nil
+}
+
+// heartbeat extends the claim on a running job until it is settled.
+func (q *PostgresQueue) heartbeat(d *postgresDelivery) {
+	ticker := time.NewTicker(q.visibilityTimeout / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			q.db.Model(&models.Job{}).
+				Where("id = ? AND status = ?", d.id, jobRunning).
+				Update("locked_at", time.Now())
+		}
+	}
+}
+
+// reclaim requeues jobs whose worker died without settling them.
+func (q *PostgresQueue) reclaim(queueName string) {
+	result := q.db.Model(&models.Job{}).
+		Where("queue = ? AND status = ? AND locked_at < ?", queueName, jobRunning, time.Now().Add(-q.visibilityTimeout)).
+		Updates(map[string]interface{}{"status": jobQueued, "locked_at": nil, "run_at": time.Now()})
+	if result.Error != nil {
+		log.Printf("Failed to reclaim stale jobs from %s: %v", queueName, result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Reclaimed %d stale jobs in %s", result.RowsAffected, queueName)
+	}
+}
+
+// settle stops the heartbeat and frees the prefetch slot exactly once.
+func (q *PostgresQueue) settle(job *Job) *postgresDelivery {
+	d := job.handle.(*postgresDelivery)
+	d.once.Do(func() {
+		close(d.stop)
+		if d.release != nil {
+			d.release()
+		}
+	})
+	return d
+}
+
+func (q *PostgresQueue) Ack(job *Job) error {
+	d := q.settle(job)
+	return q.db.Delete(&models.Job{}, d.id).Error
+}
+
+func (q *PostgresQueue) Nack(job *Job, requeue bool) error {
+	d := q.settle(job)
+
+	if requeue {
+		return q.db.Model(&models.Job{}).Where("id = ?", d.id).
+			Updates(map[string]interface{}{"status": jobQueued, "locked_at": nil, "run_at": time.Now()}).Error
+	}
+
+	return q.db.Model(&models.Job{}).Where("id = ?", d.id).
+		Updates(map[string]interface{}{"status": jobDead, "locked_at": nil, "failed_at": time.Now()}).Error
+}
+
+func (q *PostgresQueue) Retry(job *Job, body []byte, retry int) (time.Duration, error) {
+	d := q.settle(job)
+	_, delay := retryDelay(q.retryDelays, retry)
+
+	err := q.db.Model(&models.Job{}).Where("id = ?", d.id).Updates(map[string]interface{}{
+		"status":    jobQueued,
+		"locked_at": nil,
+		"run_at":    time.Now().Add(delay),
+		"body":      body,
+	}).Error
+	return delay, err
+}
+
+func (q *PostgresQueue) DeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	var rows []models.Job
+	query := q.db.Where("queue = ? AND status = ?", queueName, jobDead).Order("failed_at, id")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	letters := make([]DeadLetter, 0, len(rows))
+	for _, row := range rows {
+		letter := DeadLetter{Body: row.Body, Reason: "rejected", Count: 1}
+		if row.FailedAt != nil {
+			letter.FailedAt = *row.FailedAt
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+func (q *PostgresQueue) ReplayDeadLetters(queueName string, rewrite func(body []byte) ([]byte, bool)) (int, error) {
+	var rows []models.Job
+	if err := q.db.Where("queue = ? AND status = ?", queueName, jobDead).Order("failed_at, id").Find(&rows).Error; err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, row := range rows {
+		body, ok := rewrite(row.Body)
+		if !ok {
+			continue
+		}
+
+		err := q.db.Model(&models.Job{}).Where("id = ? AND status = ?", row.ID, jobDead).Updates(map[string]interface{}{
+			"status":    jobQueued,
+			"run_at":    time.Now(),
+			"failed_at": nil,
+			"body":      body,
+		}).Error
+		if err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+func (q *PostgresQueue) Ping(ctx context.Context) error {
+	sqlDB, err := q.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Close is a no-op; the database connection is owned by the caller.
+func (q *PostgresQueue) Close() {}
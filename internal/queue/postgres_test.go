@@ -0,0 +1,158 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"deployment-platform/internal/models"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testPostgresQueue connects to TEST_DATABASE_DSN and returns a queue name
+// that no other test uses. Tests are skipped when the variable is unset.
+func testPostgresQueue(t *testing.T, visibilityTimeout time.Duration) (*PostgresQueue, string) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Job{}); err != nil {
+		t.Fatal(err)
+	}
+
+	queueName := fmt.Sprintf("test_%s_%d", t.Name(), time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.Where("queue = ?", queueName).Delete(&models.Job{})
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return NewPostgresQueue(db, []time.Duration{time.Hour}, visibilityTimeout), queueName
+}
+
+func TestPostgresQueueClaimSkipsLockedJobs(t *testing.T) {
+	q, queueName := testPostgresQueue(t, time.Minute)
+
+	const jobs = 20
+	for i := range jobs {
+		if err := q.Publish(context.Background(), queueName, []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		claimed = make(map[string]int)
+		wg      sync.WaitGroup
+	)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, err := q.claim(queueName)
+				if err != nil {
+					return
+				}
+				q.settle(job)
+				mu.Lock()
+				claimed[string(job.Body)]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != jobs {
+		t.Fatalf("claimed %d distinct jobs, want %d", len(claimed), jobs)
+	}
+	for body, n := range claimed {
+		if n != 1 {
+			t.Errorf("job %s claimed %d times", body, n)
+		}
+	}
+}
+
+func TestPostgresQueueReclaimsStaleJobs(t *testing.T) {
+	q, queueName := testPostgresQueue(t, 100*time.Millisecond)
+	q.Publish(context.Background(), queueName, []byte("job"))
+
+	job, err := q.claim(queueName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The worker died: no heartbeat keeps the claim alive
+	if _, err := q.claim(queueName); err == nil {
+		t.Fatal("claimed a running job")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	q.reclaim(queueName)
+
+	again, err := q.claim(queueName)
+	if err != nil {
+		t.Fatalf("stale job was not reclaimed: %v", err)
+	}
+	if again.handle.(*postgresDelivery).id != job.handle.(*postgresDelivery).id {
+		t.Error("reclaimed a different job")
+	}
+	q.Ack(again)
+}
+
+func TestPostgresQueueAckNackRetry(t *testing.T) {
+	q, queueName := testPostgresQueue(t, time.Minute)
+	q.Publish(context.Background(), queueName, []byte("job"))
+
+	job, _ := q.claim(queueName)
+	q.Nack(job, true)
+	job, err := q.claim(queueName)
+	if err != nil {
+		t.Fatalf("requeued job not claimable: %v", err)
+	}
+
+	if _, err := q.Retry(job, []byte("retry"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.claim(queueName); err == nil {
+		t.Fatal("retried job claimed before its delay")
+	}
+	q.db.Model(&models.Job{}).Where("queue = ?", queueName).Update("run_at", time.Now())
+
+	job, err = q.claim(queueName)
+	if err != nil || string(job.Body) != "retry" {
+		t.Fatalf("claim after delay = %v, %v", job, err)
+	}
+	q.Nack(job, false)
+
+	letters, err := q.DeadLetters(queueName, 0)
+	if err != nil || len(letters) != 1 || string(letters[0].Body) != "retry" {
+		t.Fatalf("DeadLetters = %+v, %v", letters, err)
+	}
+	if n, err := q.ReplayDeadLetters(queueName, func(body []byte) ([]byte, bool) { return body, true }); err != nil || n != 1 {
+		t.Fatalf("ReplayDeadLetters = %d, %v", n, err)
+	}
+
+	job, err = q.claim(queueName)
+	if err != nil {
+		t.Fatalf("replayed job not claimable: %v", err)
+	}
+	q.Ack(job)
+
+	var count int64
+	q.db.Model(&models.Job{}).Where("queue = ?", queueName).Count(&count)
+	if count != 0 {
+		t.Errorf("%d jobs left after ack", count)
+	}
+}
@@ -0,0 +1,93 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"deployment-platform/internal/config"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DriverRabbitMQ = "rabbitmq"
+	DriverMemory   = "memory"
+	DriverPostgres = "postgres"
+)
+
+var ErrQueueClosed = errors.New("queue closed")
+
+// Job is a single delivery handed to a consumer. It must be settled exactly
+// once with Ack, Nack or Retry.
+type Job struct {
+	Body []byte
+
+	handle interface{} // backend-specific delivery state
+}
+
+// DeadLetter is a job that exhausted its retries or could not be processed.
+type DeadLetter struct {
+	Body     []byte    `json:"-"`
+	Reason   string    `json:"reason"`
+	Count    int64     `json:"count"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// JobQueue is a durable work queue with delayed retries and dead-lettering.
+type JobQueue interface {
+	Publish(ctx context.Context, queueName string, body []byte) error
+	// Consume delivers at most prefetch unsettled jobs at a time. Cancelling
+	// ctx stops new deliveries; the channel is closed once jobs already
+	// handed out of the backend have been delivered.
+	Consume(ctx context.Context, queueName string, prefetch int) (<-chan *Job, error)
+	Ack(job *Job) error
+	// Nack returns the job to the queue, or dead-letters it when requeue is false.
+	Nack(job *Job, requeue bool) error
+	// Retry settles job and schedules body for redelivery after the backoff
+	// delay for the given retry number (starting at 1).
+	Retry(job *Job, body []byte, retry int) (time.Duration, error)
+	DeadLetters(queueName string, limit int) ([]DeadLetter, error)
+	// ReplayDeadLetters moves dead letters back to the queue. rewrite returns
+	// the body to publish, or false to leave the message where it is.
+	ReplayDeadLetters(queueName string, rewrite func(body []byte) ([]byte, bool)) (int, error)
+	Ping(ctx context.Context) error
+	Close()
+}
+
+// New builds the backend selected by QUEUE_DRIVER and declares queueNames.
+func New(cfg *config.Config, db *gorm.DB, queueNames ...string) (JobQueue, error) {
+	delays := RetryDelays(cfg.JobRetryBaseDelay, cfg.JobMaxAttempts)
+
+	switch cfg.QueueDriver {
+	case DriverRabbitMQ:
+		return NewRabbitMQ(cfg.RabbitMQURL, delays, queueNames...)
+	case DriverMemory:
+		return NewMemoryQueue(delays), nil
+	case DriverPostgres:
+		return NewPostgresQueue(db, delays, cfg.JobVisibilityTimeout), nil
+	default:
+		return nil, fmt.Errorf("unknown queue driver %q", cfg.QueueDriver)
+	}
+}
+
+// RetryDelays returns the exponential backoff schedule for maxAttempts
+// attempts: one delay before each attempt after the first.
+func RetryDelays(base time.Duration, maxAttempts int) []time.Duration {
+	var delays []time.Duration
+	for i := 1; i < maxAttempts; i++ {
+		delays = append(delays, base<<(i-1))
+	}
+	return delays
+}
+
+// retryDelay picks the backoff for a retry number, reusing the longest
+// delay once the schedule is exhausted.
+func retryDelay(delays []time.Duration, retry int) (int, time.Duration) {
+	if len(delays) == 0 {
+		return 0, 0
+	}
+	step := min(max(retry, 1), len(delays))
+	return step, delays[step-1]
+}
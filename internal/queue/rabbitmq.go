@@ -0,0 +1,594 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+var ErrRabbitMQUnavailable = errors.New("rabbitmq unavailable")
+
+const (
+	// reconnectWait bounds how long Publish waits for a lost connection to come back
+	reconnectWait   = 5 * time.Second
+	confirmTimeout  = 10 * time.Second
+	maxReconnectGap = 30 * time.Second
+
+	// confirmBuffer absorbs bursts of confirms while they are dispatched
+	confirmBuffer = 64
+)
+
+// RabbitMQ keeps a connection to the broker alive across restarts. Topology
+// is redeclared and consumers resubscribe after every reconnect, and
+// publishes wait for broker confirmation.
+type RabbitMQ struct {
+	url         string
+	retryDelays []time.Duration
+	queueNames  []string
+
+	mu        sync.Mutex
+	conn      *amqp.Connection
+	channel   *amqp.Channel // consuming
+	connected chan struct{} // closed while a connection is up
+	shutdown  bool
+	cancelled map[string]bool
+
+	pubMu      sync.Mutex // serialises publishes so delivery tags follow pubSeq
+	pubChannel *amqp.Channel
+	confirms   *confirmTracker
+	pubSeq     uint64
+}
+
+// confirmTracker hands each broker confirm to the publish with its delivery
+// tag, so a publish that stopped waiting never takes another one's confirm.
+type confirmTracker struct {
+	mu      sync.Mutex
+	pending map[uint64]chan bool // receives whether the broker acked
+	closed  bool
+}
+
+func newConfirmTracker() *confirmTracker {
+	return &confirmTracker{pending: make(map[uint64]chan bool)}
+}
+
+// add registers a publish before it is sent. The returned channel is closed
+// without a value if the channel goes away before the confirm arrives.
+func (t *confirmTracker) add(tag uint64) (chan bool, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, false
+	}
+	waiter := make(chan bool, 1)
+	t.pending[tag] = waiter
+	return waiter, true
+}
+
+// forget drops a publish that gave up waiting; its confirm is discarded.
+func (t *confirmTracker) forget(tag uint64) {
+	t.mu.Lock()
+	delete(t.pending, tag)
+	t.mu.Unlock()
+}
+
+// dispatch runs until confirms is closed with the channel. The client stalls
+// the whole connection while confirms go unread, so they are always drained.
+func (t *confirmTracker) dispatch(confirms <-chan amqp.Confirmation) {
+	for confirm := range confirms {
+		t.mu.Lock()
+		if waiter, ok := t.pending[confirm.DeliveryTag]; ok {
+			delete(t.pending, confirm.DeliveryTag)
+			waiter <- confirm.Ack
+		}
+		t.mu.Unlock()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	for tag, waiter := range t.pending {
+		delete(t.pending, tag)
+		close(waiter)
+	}
+}
+
+// NewRabbitMQ connects and declares each queue together with its dead-letter
+// queue and one delayed retry queue per backoff step.
+func NewRabbitMQ(url string, retryDelays []time.Duration, queueNames ...string) (*RabbitMQ, error) {
+	r := &RabbitMQ{
+		url:         url,
+		retryDelays: retryDelays,
+		queueNames:  queueNames,
+		connected:   make(chan struct{}),
+		cancelled:   make(map[string]bool),
+	}
+
+	if err := r.connect(); err != nil {
+		return nil, err
+	}
+
+	log.Println("RabbitMQ connected successfully")
+
+	return r, nil
+}
+
+func (r *RabbitMQ) connect() error {
+	conn, err := amqp.Dial(r.url)
+	if err != nil {
+		return err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	for _, queueName := range r.queueNames {
+		if err := r.declareTopology(conn, ch, queueName); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	pubCh, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if err := pubCh.Confirm(false); err != nil {
+		conn.Close()
+		return err
+	}
+
+	confirms := newConfirmTracker()
+	go confirms.dispatch(pubCh.NotifyPublish(make(chan amqp.Confirmation, confirmBuffer)))
+
+	r.pubMu.Lock()
+	r.pubChannel = pubCh
+	r.confirms = confirms
+	r.pubSeq = 0
+	r.pubMu.Unlock()
+
+	r.mu.Lock()
+	r.conn = conn
+	r.channel = ch
+	close(r.connected)
+	r.mu.Unlock()
+
+	// A channel-level error leaves the connection open; recycle everything
+	// so both channels come back in a known state
+	for _, c := range []*amqp.Channel{ch, pubCh} {
+		go func(c *amqp.Channel) {
+			if <-c.NotifyClose(make(chan *amqp.Error, 1)) != nil {
+				conn.Close()
+			}
+		}(c)
+	}
+	go r.watch(conn)
+
+	return nil
+}
+
+// watch reconnects with exponential backoff once conn is lost.
+func (r *RabbitMQ) watch(conn *amqp.Connection) {
+	err := <-conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	r.mu.Lock()
+	if r.shutdown {
+		r.mu.Unlock()
+		return
+	}
+	r.connected = make(chan struct{})
+	r.mu.Unlock()
+
+	log.Printf("RabbitMQ connection lost: %v", err)
+
+	backoff := time.Second
+	for {
+		r.mu.Lock()
+		shutdown := r.shutdown
+		r.mu.Unlock()
+		if shutdown {
+			return
+		}
+
+		err := r.connect()
+		if err == nil {
+			log.Println("RabbitMQ reconnected")
+			return
+		}
+
+		log.Printf("RabbitMQ reconnect failed, retrying in %s: %v", backoff, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxReconnectGap)
+	}
+}
+
+// current waits up to timeout for a live connection.
+func (r *RabbitMQ) current(timeout time.Duration) (*amqp.Connection, *amqp.Channel, error) {
+	r.mu.Lock()
+	connected := r.connected
+	r.mu.Unlock()
+
+	select {
+	case <-connected:
+	case <-time.After(timeout):
+		return nil, nil, ErrRabbitMQUnavailable
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.shutdown {
+		return nil, nil, ErrRabbitMQUnavailable
+	}
+	return r.conn, r.channel, nil
+}
+
+// Queue arguments cannot change once a queue exists, so none of the queues
+// below depends on configuration: retry delays travel as per-message
+// expirations, and rejected jobs are published to the dead-letter queue
+// rather than dead-lettered by the broker.
+func deadLetterQueue(queueName string) string { return queueName + ".dead" }
+func retryQueue(queueName string, step int) string {
+	return fmt.Sprintf("%s.delayed.%d", queueName, step)
+}
+
+// deadLetterReason is the header recording why a job was dead-lettered.
+const deadLetterReason = "x-dead-letter-reason"
+
+func (r *RabbitMQ) declareTopology(conn *amqp.Connection, ch *amqp.Channel, queueName string) error {
+	// Earlier versions declared the main queue with other arguments; it is
+	// used as it is rather than redeclared
+	if err := ensureQueue(conn, ch, queueName); err != nil {
+		return err
+	}
+	if _, err := ch.QueueDeclare(deadLetterQueue(queueName), true, false, false, false, nil); err != nil {
+		return err
+	}
+
+	// Retry queues have no consumers; messages expire back into the main queue
+	for i := range r.retryDelays {
+		_, err := ch.QueueDeclare(retryQueue(queueName, i+1), true, false, false, false, amqp.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queueName,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensureQueue declares a durable queue without arguments unless one with
+// that name already exists, whatever its arguments.
+func ensureQueue(conn *amqp.Connection, ch *amqp.Channel, queueName string) error {
+	// A failed passive declare closes the channel, so probe on a spare one
+	probe, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	_, err = probe.QueueDeclarePassive(queueName, true, false, false, false, nil)
+	if err == nil {
+		return probe.Close()
+	}
+	var amqpErr *amqp.Error
+	if !errors.As(err, &amqpErr) || amqpErr.Code != amqp.NotFound {
+		return err
+	}
+
+	_, err = ch.QueueDeclare(
+		queueName,
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	return err
+}
+
+// Publish sends a persistent message and returns once the broker has
+// confirmed it, so a nil error means the job survives a broker restart.
+func (r *RabbitMQ) Publish(ctx context.Context, queueName string, body []byte) error {
+	return r.publish(ctx, queueName, amqp.Publishing{Body: body})
+}
+
+func (r *RabbitMQ) publish(ctx context.Context, queueName string, msg amqp.Publishing) error {
+	if _, _, err := r.current(reconnectWait); err != nil {
+		return err
+	}
+
+	msg.ContentType = "application/json"
+	msg.DeliveryMode = amqp.Persistent
+	msg.Timestamp = time.Now()
+
+	// Only tag assignment is serialised; publishes wait for confirms concurrently
+	r.pubMu.Lock()
+	confirms, tag := r.confirms, r.pubSeq+1
+	waiter, ok := confirms.add(tag)
+	if !ok {
+		r.pubMu.Unlock()
+		return fmt.Errorf("%w: channel closed", ErrRabbitMQUnavailable)
+	}
+	err := r.pubChannel.Publish(
+		"",        // exchange
+		queueName, // routing key
+		false,     // mandatory
+		false,     // immediate
+		msg,
+	)
+	if err != nil {
+		r.pubMu.Unlock()
+		confirms.forget(tag)
+		return err
+	}
+	r.pubSeq = tag
+	r.pubMu.Unlock()
+
+	timer := time.NewTimer(confirmTimeout)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		confirms.forget(tag)
+		return ctx.Err()
+	case ack, ok := <-waiter:
+		if !ok {
+			return fmt.Errorf("%w: connection closed before publish was confirmed", ErrRabbitMQUnavailable)
+		}
+		if !ack {
+			return errors.New("broker rejected message")
+		}
+		return nil
+	case <-timer.C:
+		confirms.forget(tag)
+		return errors.New("timed out waiting for publish confirmation")
+	}
+}
+
+func (r *RabbitMQ) Retry(job *Job, body []byte, retry int) (time.Duration, error) {
+	step, delay := retryDelay(r.retryDelays, retry)
+	d := job.handle.(rabbitDelivery)
+
+	msg := amqp.Publishing{Body: body}
+	target := d.queueName
+	if step > 0 {
+		target = retryQueue(d.queueName, step)
+		msg.Expiration = strconv.FormatInt(delay.Milliseconds(), 10)
+	}
+	if err := r.publish(context.Background(), target, msg); err != nil {
+		return 0, err
+	}
+	return delay, d.Ack(false)
+}
+
+func (r *RabbitMQ) Ack(job *Job) error {
+	return job.handle.(rabbitDelivery).Ack(false)
+}
+
+// Nack without requeue moves the message to the dead-letter queue.
+func (r *RabbitMQ) Nack(job *Job, requeue bool) error {
+	d := job.handle.(rabbitDelivery)
+	if requeue {
+		return d.Nack(false, true)
+	}
+
+	err := r.publish(context.Background(), deadLetterQueue(d.queueName), amqp.Publishing{
+		Headers: amqp.Table{deadLetterReason: "rejected"},
+		Body:    d.Body,
+	})
+	if err != nil {
+		return err
+	}
+	return d.Ack(false)
+}
+
+// rabbitDelivery remembers which queue a delivery came from for retries.
+type rabbitDelivery struct {
+	amqp.Delivery
+	queueName string
+}
+
+// Consume returns deliveries for queueName that keep flowing across
+// reconnects. The channel is closed after ctx is cancelled or on Close.
+func (r *RabbitMQ) Consume(ctx context.Context, queueName string, prefetch int) (<-chan *Job, error) {
+	consumerTag := fmt.Sprintf("%s-%d", queueName, time.Now().UnixNano())
+
+	deliveries, err := r.subscribe(queueName, consumerTag, prefetch)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := r.cancelConsumer(consumerTag); err != nil {
+			log.Printf("Failed to cancel consumer %s: %v", consumerTag, err)
+		}
+	}()
+
+	out := make(chan *Job)
+	go func() {
+		defer close(out)
+		for {
+			// Deliveries stop when the consumer is cancelled or the connection drops
+			for d := range deliveries {
+				out <- &Job{Body: d.Body, handle: rabbitDelivery{Delivery: d, queueName: queueName}}
+			}
+
+			for {
+				r.mu.Lock()
+				done := r.shutdown || r.cancelled[consumerTag]
+				r.mu.Unlock()
+				if done {
+					return
+				}
+
+				deliveries, err = r.subscribe(queueName, consumerTag, prefetch)
+				if err == nil {
+					log.Printf("Consumer %s resubscribed to %s", consumerTag, queueName)
+					break
+				}
+				time.Sleep(time.Second)
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (r *RabbitMQ) subscribe(queueName, consumerTag string, prefetch int) (<-chan amqp.Delivery, error) {
+	_, ch, err := r.current(maxReconnectGap)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		return nil, err
+	}
+
+	return ch.Consume(
+		queueName,
+		consumerTag,
+		false, // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // args
+	)
+}
+
+// cancelConsumer stops new deliveries; the delivery channel is closed once
+// the broker confirms.
+func (r *RabbitMQ) cancelConsumer(consumerTag string) error {
+	r.mu.Lock()
+	r.cancelled[consumerTag] = true
+	ch := r.channel
+	r.mu.Unlock()
+
+	return ch.Cancel(consumerTag, false)
+}
+
+// DeadLetters returns up to limit dead-lettered messages without removing them.
+func (r *RabbitMQ) DeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	var letters []DeadLetter
+	err := r.drainDeadLetters(queueName, limit, func(msg amqp.Delivery) error {
+		letters = append(letters, parseDeadLetter(msg))
+		return nil
+	})
+	return letters, err
+}
+
+// ReplayDeadLetters moves dead-lettered messages back to queueName. rewrite
+// returns the body to publish, or false to leave the message where it is.
+func (r *RabbitMQ) ReplayDeadLetters(queueName string, rewrite func(body []byte) ([]byte, bool)) (int, error) {
+	replayed := 0
+	err := r.drainDeadLetters(queueName, 0, func(msg amqp.Delivery) error {
+		body, ok := rewrite(msg.Body)
+		if !ok {
+			return nil
+		}
+		if err := r.Publish(context.Background(), queueName, body); err != nil {
+			return err
+		}
+		replayed++
+		return msg.Ack(false)
+	})
+	return replayed, err
+}
+
+// drainDeadLetters fetches dead letters one by one on a private channel.
+// Messages that fn does not ack are returned to the queue, in order, when
+// the channel closes.
+func (r *RabbitMQ) drainDeadLetters(queueName string, limit int, fn func(amqp.Delivery) error) error {
+	conn, _, err := r.current(reconnectWait)
+	if err != nil {
+		return err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueInspect(deadLetterQueue(queueName))
+	if err != nil {
+		return err
+	}
+
+	n := q.Messages
+	if limit > 0 && limit < n {
+		n = limit
+	}
+
+	for i := 0; i < n; i++ {
+		msg, ok, err := ch.Get(deadLetterQueue(queueName), false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseDeadLetter(msg amqp.Delivery) DeadLetter {
+	letter := DeadLetter{Body: msg.Body, FailedAt: msg.Timestamp, Count: 1}
+	letter.Reason, _ = msg.Headers[deadLetterReason].(string)
+
+	// Letters dead-lettered by the broker, from earlier versions
+	deaths, _ := msg.Headers["x-death"].([]interface{})
+	if len(deaths) == 0 {
+		return letter
+	}
+	if death, ok := deaths[0].(amqp.Table); ok {
+		letter.Reason, _ = death["reason"].(string)
+		letter.Count, _ = death["count"].(int64)
+		if t, ok := death["time"].(time.Time); ok {
+			letter.FailedAt = t
+		}
+	}
+
+	return letter
+}
+
+func (r *RabbitMQ) Ping(ctx context.Context) error {
+	if r.isClosed() {
+		return ErrRabbitMQUnavailable
+	}
+	return nil
+}
+
+// isClosed reports whether the broker is currently unreachable.
+func (r *RabbitMQ) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	select {
+	case <-r.connected:
+		return r.shutdown || r.conn.IsClosed()
+	default:
+		return true
+	}
+}
+
+func (r *RabbitMQ) Close() {
+	r.mu.Lock()
+	r.shutdown = true
+	conn := r.conn
+	r.mu.Unlock()
+
+	conn.Close()
+}
@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/streadway/amqp"
+)
+
+func TestConfirmTrackerMatchesDeliveryTags(t *testing.T) {
+	tracker := newConfirmTracker()
+	confirms := make(chan amqp.Confirmation)
+	done := make(chan struct{})
+	go func() {
+		tracker.dispatch(confirms)
+		close(done)
+	}()
+
+	first, _ := tracker.add(1)
+	second, _ := tracker.add(2)
+	third, _ := tracker.add(3)
+
+	// The first publish timed out; its late confirm must not reach the others
+	tracker.forget(1)
+	confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	confirms <- amqp.Confirmation{DeliveryTag: 3, Ack: false}
+	confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+
+	if ack := <-second; !ack {
+		t.Error("publish 2 was not acked")
+	}
+	if ack := <-third; ack {
+		t.Error("publish 3 was acked, want nacked")
+	}
+	select {
+	case ack := <-first:
+		t.Errorf("forgotten publish 1 received confirm %v", ack)
+	default:
+	}
+
+	// Publishes still waiting when the channel closes are released
+	fourth, _ := tracker.add(4)
+	close(confirms)
+	<-done
+	if _, ok := <-fourth; ok {
+		t.Error("publish 4 received a confirm after the channel closed")
+	}
+	if _, ok := tracker.add(5); ok {
+		t.Error("registered a publish on a closed channel")
+	}
+}
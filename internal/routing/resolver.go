@@ -0,0 +1,223 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/services"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotFound = errors.New("deployment not found")
+	ErrGone     = errors.New("deployment has been deleted")
+)
+
+// Deployment states cached by the resolver
+const (
+	stateDeployed = "deployed"
+	stateDeleted  = "deleted"
+	stateMissing  = "missing"
+)
+
+// cacheTTL is kept short because a lookup made just before a deletion or
+// alias change can be cached after the API has already invalidated it.
+const cacheTTL = time.Minute
+
+// Cached routes carry a prefix so a route without a target can be told
+// apart from a cached miss (an empty value)
+const (
+	deployPrefix = "="
+	aliasPrefix  = "@"
+)
+
+// Resolver maps request hosts to the deployment that should serve them.
+type Resolver struct {
+	db       *gorm.DB
+	redis    *services.RedisService
+	baseHost string
+}
+
+func NewResolver(db *gorm.DB, redis *services.RedisService, baseDomain string) *Resolver {
+	return &Resolver{db: db, redis: redis, baseHost: Hostname(baseDomain)}
+}
+
+// Resolve returns the deploy ID serving host. Aliases are consulted first,
+// then <deploy-id>.<base domain> gives every deployment its immutable URL,
+// and any other host must be a verified custom domain.
+func (r *Resolver) Resolve(ctx context.Context, host string) (string, error) {
+	hostname := Hostname(host)
+
+	if deployID, ok := r.aliasTarget(ctx, hostname); ok {
+		return r.servable(ctx, deployID)
+	}
+
+	if label, parent, ok := strings.Cut(hostname, "."); ok && parent == r.baseHost {
+		return r.servable(ctx, label)
+	}
+
+	if deployID, ok := r.domainTarget(ctx, hostname); ok {
+		return r.servable(ctx, deployID)
+	}
+	return "", ErrNotFound
+}
+
+// IsCustomDomain reports whether host is a verified custom domain, the only
+// hosts certificates are requested for one by one.
+func (r *Resolver) IsCustomDomain(ctx context.Context, host string) bool {
+	hostname := Hostname(host)
+	if hostname == r.baseHost || strings.HasSuffix(hostname, "."+r.baseHost) {
+		return false
+	}
+
+	_, ok := r.domainTarget(ctx, hostname)
+	return ok
+}
+
+func (r *Resolver) servable(ctx context.Context, deployID string) (string, error) {
+	if deployID == "" {
+		return "", ErrNotFound
+	}
+
+	switch r.deploymentState(ctx, deployID) {
+	case stateDeployed:
+		return deployID, nil
+	case stateDeleted:
+		return "", ErrGone
+	default:
+		return "", ErrNotFound
+	}
+}
+
+// deploymentState reports whether a deployment can be served, consulting the
+// Redis cache before the database. Only settled states are cached so a build
+// that finishes is served right away.
+func (r *Resolver) deploymentState(ctx context.Context, deployID string) string {
+	key := services.DeploymentStateKey(deployID)
+	if cached, err := r.redis.Get(ctx, key); err == nil {
+		return string(cached)
+	}
+
+	var deployment models.Deployment
+	err := r.db.Unscoped().Select("status", "deleted_at").Where("deploy_id = ?", deployID).First(&deployment).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error looking up deployment %s: %v", deployID, err)
+		}
+		return stateMissing
+	}
+
+	var state string
+	switch {
+	case deployment.DeletedAt.Valid:
+		state = stateDeleted
+	case deployment.Status == "deployed":
+		state = stateDeployed
+	default:
+		return stateMissing
+	}
+
+	if err := r.redis.Set(ctx, key, []byte(state), cacheTTL); err != nil {
+		log.Printf("Failed to cache deployment state: %v", err)
+	}
+	return state
+}
+
+// aliasTarget looks up the alias for hostname. Misses are cached as well,
+// since most hosts are plain deployment URLs. An alias without a target
+// yet reports ok with an empty deploy ID.
+func (r *Resolver) aliasTarget(ctx context.Context, hostname string) (string, bool) {
+	key := services.AliasCacheKey(hostname)
+	if cached, err := r.redis.Get(ctx, key); err == nil {
+		if len(cached) == 0 {
+			return "", false
+		}
+		return strings.TrimPrefix(string(cached), deployPrefix), true
+	}
+
+	var alias models.Alias
+	err := r.db.Select("deploy_id").Where("hostname = ?", hostname).First(&alias).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Error looking up alias %s: %v", hostname, err)
+		return "", false
+	}
+
+	value := ""
+	if err == nil {
+		value = deployPrefix + alias.DeployID
+	}
+	if err := r.redis.Set(ctx, key, []byte(value), cacheTTL); err != nil {
+		log.Printf("Failed to cache alias: %v", err)
+	}
+	return alias.DeployID, err == nil
+}
+
+// domainTarget routes a verified custom domain. Domains attached to a
+// project follow the project's production alias.
+func (r *Resolver) domainTarget(ctx context.Context, hostname string) (string, bool) {
+	key := services.DomainCacheKey(hostname)
+	cached, err := r.redis.Get(ctx, key)
+	if err != nil {
+		route, err := r.lookupDomain(hostname)
+		if err != nil {
+			log.Printf("Error looking up domain %s: %v", hostname, err)
+			return "", false
+		}
+		cached = []byte(route)
+		if err := r.redis.Set(ctx, key, cached, cacheTTL); err != nil {
+			log.Printf("Failed to cache domain: %v", err)
+		}
+	}
+
+	route := string(cached)
+	switch {
+	case strings.HasPrefix(route, deployPrefix):
+		return strings.TrimPrefix(route, deployPrefix), true
+	case strings.HasPrefix(route, aliasPrefix):
+		return r.aliasTarget(ctx, strings.TrimPrefix(route, aliasPrefix))
+	default:
+		return "", false
+	}
+}
+
+// lookupDomain returns the cacheable route of a domain, empty when it is
+// not a verified domain.
+func (r *Resolver) lookupDomain(hostname string) (string, error) {
+	var domain models.Domain
+	err := r.db.Where("hostname = ? AND verified_at IS NOT NULL", hostname).First(&domain).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if domain.ProjectID == nil {
+		return deployPrefix + domain.DeployID, nil
+	}
+
+	var alias models.Alias
+	err = r.db.Select("hostname").Where("project_id = ?", *domain.ProjectID).First(&alias).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return aliasPrefix + alias.Hostname, nil
+}
+
+// Hostname normalises a Host header or domain for lookups: lower case and
+// without a port.
+func Hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -0,0 +1,158 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"deployment-platform/internal/config"
+)
+
+// Sealed values are prefixed with their format so the key or cipher can
+// change later: v1 values were encrypted with the master key directly, v2
+// values are envelopes holding their own data key wrapped by the master key.
+const (
+	version         = "v1:"
+	envelopeVersion = "v2:"
+)
+
+var ErrMalformed = errors.New("malformed sealed value")
+
+// Box encrypts secrets stored in the database (git credentials, environment
+// variables) with AES-256-GCM under a master key from config.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New builds a Box from SECRETS_KEY, a base64-encoded 32-byte key. The API
+// and workers must share the key, so it is never derived from other settings.
+func New(cfg *config.Config) (*Box, error) {
+	if cfg.SecretsKey == "" {
+		return nil, errors.New("SECRETS_KEY is required, generate one with: openssl rand -base64 32")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(cfg.SecretsKey)
+	if err != nil {
+		return nil, fmt.Errorf("SECRETS_KEY is not valid base64: %w", err)
+	}
+	return NewBox(key)
+}
+
+func NewBox(key []byte) (*Box, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts plaintext with a fresh data key and stores that key,
+// encrypted with the master key, alongside the ciphertext. Rotating the
+// master key then only means re-wrapping data keys.
+func (b *Box) Seal(plaintext string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	wrappedKey, err := seal(b.aead, dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(aead, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return envelopeVersion + base64.StdEncoding.EncodeToString(wrappedKey) + "." +
+		base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value produced by Seal, or a v1 value.
+func (b *Box) Open(sealed string) (string, error) {
+	if encoded, ok := strings.CutPrefix(sealed, envelopeVersion); ok {
+		return b.openEnvelope(encoded)
+	}
+
+	encoded, ok := strings.CutPrefix(sealed, version)
+	if !ok {
+		return "", ErrMalformed
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrMalformed
+	}
+	plaintext, err := open(b.aead, data)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func (b *Box) openEnvelope(encoded string) (string, error) {
+	keyPart, dataPart, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return "", ErrMalformed
+	}
+	wrappedKey, err := base64.StdEncoding.DecodeString(keyPart)
+	if err != nil {
+		return "", ErrMalformed
+	}
+	data, err := base64.StdEncoding.DecodeString(dataPart)
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	dataKey, err := open(b.aead, wrappedKey)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", ErrMalformed
+	}
+	plaintext, err := open(aead, data)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns nonce || ciphertext.
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting secret: %w", err)
+	}
+	return plaintext, nil
+}
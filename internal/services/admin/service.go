@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/queue"
+	"deployment-platform/internal/services"
+
+	"gorm.io/gorm"
+)
+
+var ErrDeploymentNotFound = errors.New("deployment not found")
+
+type DeadLetter struct {
+	DeployID string `json:"deploy_id"`
+	queue.DeadLetter
+}
+
+type Service interface {
+	ListDeadLetters(ctx context.Context, limit int) ([]DeadLetter, error)
+	ReplayDeadLetters(ctx context.Context, deployID string) (int, error)
+	PurgeDeployment(ctx context.Context, deployID string) error
+}
+
+type service struct {
+	db            *gorm.DB
+	deployService *services.DeployService
+}
+
+func NewService(db *gorm.DB, deployService *services.DeployService) Service {
+	return &service{db: db, deployService: deployService}
+}
+
+func (s *service) ListDeadLetters(ctx context.Context, limit int) ([]DeadLetter, error) {
+	letters, err := s.deployService.DeadLetters(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]DeadLetter, 0, len(letters))
+	for _, letter := range letters {
+		var job struct {
+			DeployID string `json:"deploy_id"`
+		}
+		json.Unmarshal(letter.Body, &job)
+		result = append(result, DeadLetter{DeployID: job.DeployID, DeadLetter: letter})
+	}
+	return result, nil
+}
+
+func (s *service) ReplayDeadLetters(ctx context.Context, deployID string) (int, error) {
+	return s.deployService.ReplayDeadLetters(deployID)
+}
+
+// PurgeDeployment hard-deletes a deployment, including ones users already
+// soft-deleted, after removing its objects and cache entries.
+func (s *service) PurgeDeployment(ctx context.Context, deployID string) error {
+	var deployment models.Deployment
+	if err := s.db.Unscoped().Where("deploy_id = ?", deployID).First(&deployment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDeploymentNotFound
+		}
+		return err
+	}
+
+	if err := s.deployService.PurgeDeployment(ctx, deployID); err != nil {
+		return err
+	}
+
+	return s.db.Unscoped().Delete(&deployment).Error
+}
@@ -0,0 +1,274 @@
+package alias
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/routing"
+	"deployment-platform/internal/services"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAliasNotFound      = errors.New("alias not found")
+	ErrNameTaken          = errors.New("alias name is already in use")
+	ErrDeploymentNotFound = errors.New("deployment not found")
+	ErrNotDeployed        = errors.New("deployment has not been deployed successfully")
+	ErrWrongProject       = errors.New("deployment does not belong to the alias's project")
+	ErrProjectAlias       = errors.New("alias is managed by its project, delete or rename the project instead")
+	ErrNoPreviousTarget   = errors.New("no previous successful deployment to roll back to")
+)
+
+var (
+	namePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+	// Deploy IDs are 8 lowercase alphanumerics and share the same subdomain
+	// space, so names of that shape are reserved for them
+	deployIDPattern = regexp.MustCompile(`^[a-z0-9]{8}$`)
+)
+
+// ValidateName checks that name can be used as a subdomain of the base
+// domain without shadowing deployment URLs.
+func ValidateName(name string) error {
+	switch {
+	case len(name) < 3 || len(name) > 48:
+		return errors.New("must be between 3 and 48 characters")
+	case !namePattern.MatchString(name):
+		return errors.New("may only contain lowercase letters, digits and inner hyphens")
+	case deployIDPattern.MatchString(name):
+		return errors.New("names of 8 letters and digits are reserved for deployment URLs, add a hyphen")
+	}
+	return nil
+}
+
+// ValidationError is returned for input the client has to fix.
+type ValidationError struct {
+	msg string
+}
+
+func (e *ValidationError) Error() string { return e.msg }
+
+type Service interface {
+	CreateAlias(ctx context.Context, userID uint, name, deployID string) (*models.Alias, error)
+	GetAlias(ctx context.Context, userID uint, name string) (*models.Alias, error)
+	ListAliases(ctx context.Context, userID uint) ([]models.Alias, error)
+	UpdateAlias(ctx context.Context, userID uint, name, deployID string) (*models.Alias, error)
+	DeleteAlias(ctx context.Context, userID uint, name string) error
+	Rollback(ctx context.Context, userID uint, name string) (*models.Alias, error)
+	History(ctx context.Context, userID uint, name string) ([]models.AliasTarget, error)
+	AliasURL(alias *models.Alias) string
+}
+
+type service struct {
+	db         *gorm.DB
+	redis      *services.RedisService
+	baseDomain string
+}
+
+func NewService(db *gorm.DB, redis *services.RedisService, baseDomain string) Service {
+	return &service{
+		db:         db,
+		redis:      redis,
+		baseDomain: baseDomain,
+	}
+}
+
+// Hostname is the hostname an alias name is served on.
+func Hostname(name, baseDomain string) string {
+	return routing.Hostname(name + "." + baseDomain)
+}
+
+func (s *service) CreateAlias(ctx context.Context, userID uint, name, deployID string) (*models.Alias, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, &ValidationError{msg: fmt.Sprintf("invalid name: %v", err)}
+	}
+
+	alias := &models.Alias{
+		UserID:   userID,
+		Name:     name,
+		Hostname: Hostname(name, s.baseDomain),
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkTarget(tx, alias, deployID); err != nil {
+			return err
+		}
+		return services.PointAlias(tx, alias, deployID)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrNameTaken
+		}
+		return nil, err
+	}
+
+	s.redis.InvalidateAlias(ctx, alias.Hostname)
+	return alias, nil
+}
+
+func (s *service) GetAlias(ctx context.Context, userID uint, name string) (*models.Alias, error) {
+	return findAlias(s.db, userID, name)
+}
+
+func (s *service) ListAliases(ctx context.Context, userID uint) ([]models.Alias, error) {
+	var aliases []models.Alias
+	if err := s.db.Where("user_id = ?", userID).Order("name").Find(&aliases).Error; err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// UpdateAlias promotes deployID to the alias.
+func (s *service) UpdateAlias(ctx context.Context, userID uint, name, deployID string) (*models.Alias, error) {
+	var alias *models.Alias
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		alias, err = findAlias(tx.Clauses(clause.Locking{Strength: "UPDATE"}), userID, name)
+		if err != nil {
+			return err
+		}
+		if alias.DeployID == deployID {
+			return nil
+		}
+		if err := checkTarget(tx, alias, deployID); err != nil {
+			return err
+		}
+		return services.PointAlias(tx, alias, deployID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.redis.InvalidateAlias(ctx, alias.Hostname)
+	return alias, nil
+}
+
+func (s *service) DeleteAlias(ctx context.Context, userID uint, name string) error {
+	alias, err := findAlias(s.db, userID, name)
+	if err != nil {
+		return err
+	}
+	if alias.ProjectID != nil {
+		return ErrProjectAlias
+	}
+
+	if err := s.db.Delete(alias).Error; err != nil {
+		return err
+	}
+
+	s.redis.InvalidateAlias(ctx, alias.Hostname)
+	return nil
+}
+
+// Rollback repoints the alias at the most recent earlier target that is
+// still deployed. Targets skipped on the way are marked rolled back too, so
+// repeated rollbacks keep walking back through the history.
+func (s *service) Rollback(ctx context.Context, userID uint, name string) (*models.Alias, error) {
+	var alias *models.Alias
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		alias, err = findAlias(tx.Clauses(clause.Locking{Strength: "UPDATE"}), userID, name)
+		if err != nil {
+			return err
+		}
+
+		var targets []models.AliasTarget
+		if err := tx.Where("alias_id = ? AND rolled_back_at IS NULL", alias.ID).
+			Order("id DESC").Find(&targets).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var rolledBack []uint
+		for _, target := range targets {
+			if target.DeployID != alias.DeployID && isDeployed(tx, target.DeployID) {
+				if err := tx.Model(&models.AliasTarget{}).Where("id IN ?", rolledBack).
+					Update("rolled_back_at", now).Error; err != nil {
+					return err
+				}
+				alias.DeployID = target.DeployID
+				return tx.Model(alias).Update("deploy_id", target.DeployID).Error
+			}
+			rolledBack = append(rolledBack, target.ID)
+		}
+		return ErrNoPreviousTarget
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.redis.InvalidateAlias(ctx, alias.Hostname)
+	return alias, nil
+}
+
+func (s *service) History(ctx context.Context, userID uint, name string) ([]models.AliasTarget, error) {
+	alias, err := findAlias(s.db, userID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []models.AliasTarget
+	if err := s.db.Where("alias_id = ?", alias.ID).Order("id DESC").Find(&targets).Error; err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+func (s *service) AliasURL(alias *models.Alias) string {
+	return fmt.Sprintf("http://%s.%s", alias.Name, s.baseDomain)
+}
+
+func findAlias(db *gorm.DB, userID uint, name string) (*models.Alias, error) {
+	var alias models.Alias
+	if err := db.Where("user_id = ? AND name = ?", userID, name).First(&alias).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAliasNotFound
+		}
+		return nil, err
+	}
+	return &alias, nil
+}
+
+// checkTarget makes sure a deployment can be served through the alias: it
+// belongs to the alias owner, is live, and for project aliases comes from
+// that project.
+func checkTarget(tx *gorm.DB, alias *models.Alias, deployID string) error {
+	var deployment models.Deployment
+	if err := tx.Where("deploy_id = ? AND user_id = ?", deployID, alias.UserID).First(&deployment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDeploymentNotFound
+		}
+		return err
+	}
+
+	if deployment.Status != "deployed" {
+		return ErrNotDeployed
+	}
+	if alias.ProjectID != nil && (deployment.ProjectID == nil || *deployment.ProjectID != *alias.ProjectID) {
+		return ErrWrongProject
+	}
+	return nil
+}
+
+func isDeployed(tx *gorm.DB, deployID string) bool {
+	var count int64
+	tx.Model(&models.Deployment{}).Where("deploy_id = ? AND status = ?", deployID, "deployed").Count(&count)
+	return count > 0
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// IsNameTaken reports whether err means another alias already uses the
+// hostname, so callers creating aliases indirectly can report it.
+func IsNameTaken(err error) bool {
+	return errors.Is(err, ErrNameTaken) || isUniqueViolation(err)
+}
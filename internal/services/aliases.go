@@ -0,0 +1,23 @@
+package services
+
+import (
+	"deployment-platform/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// PointAlias repoints an alias, creating it if it has no ID yet, and records
+// the new target so it can be rolled back to later. Callers lock the alias
+// row and invalidate its cache entry once tx commits.
+func PointAlias(tx *gorm.DB, alias *models.Alias, deployID string) error {
+	alias.DeployID = deployID
+	if alias.ID == 0 {
+		if err := tx.Create(alias).Error; err != nil {
+			return err
+		}
+	} else if err := tx.Model(alias).Update("deploy_id", deployID).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&models.AliasTarget{AliasID: alias.ID, DeployID: deployID}).Error
+}
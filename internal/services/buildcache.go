@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"time"
+
+	"deployment-platform/internal/archive"
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/services/builder"
+	"deployment-platform/internal/storage"
+	"deployment-platform/internal/utils"
+)
+
+// buildCacheDir holds package manager caches inside the workspace, see
+// builder.RunSpec.CacheDir.
+const buildCacheDir = ".gopher-cache"
+
+// cacheKeyFiles decide the cache key: the lockfile the package manager is
+// picked by, else package.json, so caches are at least reused while
+// dependencies don't change.
+func cacheKeyFiles() []string {
+	var names []string
+	for _, lf := range builder.Lockfiles {
+		names = append(names, lf.Name)
+	}
+	return append(names, "package.json")
+}
+
+// buildCache locates a deployment's dependency cache in object storage.
+// Caches are scoped to the user as well as the repository, so nobody can
+// plant node_modules that another user's build then runs.
+type buildCache struct {
+	prefix string   // shared by every cache of the user's repository
+	key    string   // the cache for the current lockfile
+	paths  []string // what is cached, relative to the workspace
+}
+
+// newBuildCache returns nil when caching is disabled or there is nothing to
+// key the cache on, which includes uploads that don't belong to a
+// repository. installDir is where dependencies are installed from,
+// the workspace root for projects in a monorepo workspace.
+func (s *DeployService) newBuildCache(deployment *models.Deployment, workspace, rootDir, installDir string) *buildCache {
+	if !s.opts.CacheEnabled || deployment.RepoURL == "" {
+		return nil
+	}
+
+	root, err := os.OpenRoot(workspace)
+	if err != nil {
+		return nil
+	}
+	defer root.Close()
+
+	var lockfile []byte
+	for _, dir := range []string{installDir, rootDir, "."} {
+		for _, name := range cacheKeyFiles() {
+			if lockfile, err = root.ReadFile(path.Join(dir, name)); err == nil {
+				break
+			}
+		}
+		if lockfile != nil {
+			break
+		}
+	}
+	if lockfile == nil {
+		return nil
+	}
+
+	scope := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", deployment.UserID, utils.RepoKey(deployment.RepoURL))))
+	lock := sha256.Sum256(append([]byte(rootDir+"\x00"), lockfile...))
+	prefix := fmt.Sprintf("cache/%s/", hex.EncodeToString(scope[:16]))
+
+	paths := []string{buildCacheDir, "node_modules"}
+	for _, dir := range []string{installDir, rootDir} {
+		if dir != "." && !slices.Contains(paths, path.Join(dir, "node_modules")) {
+			paths = append(paths, path.Join(dir, "node_modules"))
+		}
+	}
+	paths = append(paths, path.Join(rootDir, ".next", "cache"))
+
+	return &buildCache{
+		prefix: prefix,
+		key:    prefix + hex.EncodeToString(lock[:16]) + ".tar.gz",
+		paths:  paths,
+	}
+}
+
+// cacheDir is where build steps keep package manager caches.
+func (s *DeployService) cacheDir() string {
+	if !s.opts.CacheEnabled {
+		return ""
+	}
+	return buildCacheDir
+}
+
+// restoreCache unpacks the cache for the current lockfile, or else the
+// newest cache of the repository, whose package manager caches still save
+// downloads. It reports whether the exact cache was found. Failures only
+// cost build time, so they are logged and the build goes on.
+func (s *DeployService) restoreCache(ctx context.Context, cache *buildCache, workspace, deployID string) bool {
+	key, exact := cache.key, true
+	if info, err := s.store.Head(ctx, cache.key); err != nil || s.cacheExpired(info) {
+		exact = false
+		candidates := s.listCaches(ctx, cache.prefix)
+		if len(candidates) == 0 || s.cacheExpired(&candidates[0]) {
+			s.logs.BroadcastLog(deployID, "No build cache found")
+			return false
+		}
+		key = candidates[0].Key
+	}
+
+	obj, err := s.store.Get(ctx, key, nil)
+	if err != nil {
+		log.Printf("Failed to fetch build cache %s: %v", key, err)
+		return false
+	}
+	defer obj.Body.Close()
+
+	// Uncompressed caches are bounded too, they were produced by untrusted builds
+	limits := archive.Limits{MaxBytes: 8 * s.opts.CacheMaxSize}
+	if err := archive.ExtractTarGz(obj.Body, workspace, limits); err != nil {
+		log.Printf("Failed to restore build cache %s: %v", key, err)
+		s.logs.BroadcastLog(deployID, "Build cache could not be restored, building without it")
+		s.clearCachePaths(cache, workspace)
+		return false
+	}
+
+	if exact {
+		s.logs.BroadcastLog(deployID, "Restored build cache")
+	} else {
+		s.logs.BroadcastLog(deployID, "Restored build cache of an earlier lockfile")
+	}
+	return exact
+}
+
+// saveCache uploads the workspace's cached paths under the current
+// lockfile's key and evicts old caches of the repository.
+func (s *DeployService) saveCache(ctx context.Context, cache *buildCache, workspace, deployID string) {
+	tmp, err := os.CreateTemp("", "gopher-cache-*.tar.gz")
+	if err != nil {
+		log.Printf("Failed to create build cache archive: %v", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := archive.CreateTarGz(tmp, workspace, cache.paths); err != nil {
+		log.Printf("Failed to archive build cache for %s: %v", deployID, err)
+		return
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	if size > s.opts.CacheMaxSize {
+		s.logs.BroadcastLog(deployID, fmt.Sprintf("Build cache is %d MB, over the %d MB limit, not saving it", size>>20, s.opts.CacheMaxSize>>20))
+		return
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+
+	if err := s.store.Put(ctx, cache.key, tmp, size, "application/gzip"); err != nil {
+		log.Printf("Failed to upload build cache for %s: %v", deployID, err)
+		return
+	}
+	s.logs.BroadcastLog(deployID, fmt.Sprintf("Saved build cache (%.1f MB)", float64(size)/(1<<20)))
+
+	s.evictCaches(ctx, cache)
+}
+
+// evictCaches keeps the CacheKeep newest caches of the repository and
+// deletes expired ones.
+func (s *DeployService) evictCaches(ctx context.Context, cache *buildCache) {
+	for i, info := range s.listCaches(ctx, cache.prefix) {
+		if info.Key == cache.key || (i < s.opts.CacheKeep && !s.cacheExpired(&info)) {
+			continue
+		}
+		if _, err := s.store.DeleteByPrefix(ctx, info.Key); err != nil {
+			log.Printf("Failed to evict build cache %s: %v", info.Key, err)
+		}
+	}
+}
+
+// listCaches returns a repository's caches, newest first.
+func (s *DeployService) listCaches(ctx context.Context, prefix string) []storage.ObjectInfo {
+	infos, err := s.store.List(ctx, prefix)
+	if err != nil {
+		log.Printf("Failed to list build caches: %v", err)
+		return nil
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].LastModified.After(infos[j].LastModified)
+	})
+	return infos
+}
+
+func (s *DeployService) cacheExpired(info *storage.ObjectInfo) bool {
+	return s.opts.CacheTTL > 0 && time.Since(info.LastModified) > s.opts.CacheTTL
+}
+
+// clearCachePaths removes whatever a failed restore left behind.
+func (s *DeployService) clearCachePaths(cache *buildCache, workspace string) {
+	root, err := os.OpenRoot(workspace)
+	if err != nil {
+		return
+	}
+	defer root.Close()
+
+	for _, p := range cache.paths {
+		root.RemoveAll(p)
+	}
+}
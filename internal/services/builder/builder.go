@@ -0,0 +1,127 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// maxPackageJSONSize bounds package.json, which is read into memory.
+const maxPackageJSONSize = 4 << 20
+
+// Plan describes how a cloned project is turned into static output.
+type Plan struct {
+	Framework string
+	Install   []string // nil means there is nothing to install
+	Build     []string // nil means the project is served as-is
+	OutputDir string   // relative to the project directory
+	Toolchain string   // image the steps run in, empty for Node.js
+
+	PackageManager *PackageManager // nil for projects without package.json
+	InstallDir     string          // workspace root relative to the project directory, empty outside workspaces
+}
+
+// Builder detects a framework in a project tree and produces a build plan for it.
+type Builder interface {
+	Name() string
+	Detect(p *Project) bool
+	Plan(p *Project) *Plan
+}
+
+// Project is a read-only view over a cloned repository used by detectors.
+type Project struct {
+	Dir         string
+	PackageJSON *PackageJSON
+	Workspace   *Project // workspace root the project belongs to, nil if none
+}
+
+type PackageJSON struct {
+	PackageManager  string            `json:"packageManager"` // Corepack pin, e.g. "pnpm@9.1.0"
+	Scripts         map[string]string `json:"scripts"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+	Engines         map[string]string `json:"engines"` // e.g. {"node": ">=18"}
+	Workspaces      json.RawMessage   `json:"workspaces"`
+}
+
+// builders are tried in order; more specific frameworks come before generic fallbacks.
+var builders = []Builder{
+	nextBuilder{},
+	astroBuilder{},
+	viteBuilder{},
+	craBuilder{},
+	hugoBuilder{},
+	nodeBuilder{},
+	staticBuilder{},
+}
+
+func LoadProject(dir string) (*Project, error) {
+	p := &Project{Dir: dir}
+
+	data, err := readFile(dir, "package.json", maxPackageJSONSize)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return p, nil
+		}
+		return nil, err
+	}
+
+	var pkg PackageJSON
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("invalid package.json: %w", err)
+	}
+	p.PackageJSON = &pkg
+
+	return p, nil
+}
+
+// Detect inspects the project at dir, inside the repository cloned to
+// repoDir, and returns the plan of the first matching builder.
+func Detect(dir, repoDir string) (*Plan, error) {
+	p, err := LoadProject(dir)
+	if err != nil {
+		return nil, err
+	}
+	if p.Workspace, err = FindWorkspace(dir, repoDir); err != nil {
+		return nil, err
+	}
+
+	for _, b := range builders {
+		if b.Detect(p) {
+			return b.Plan(p), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unable to detect framework: no package.json build script or index.html found")
+}
+
+func (p *Project) HasFile(names ...string) bool {
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(p.Dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Project) HasDependency(name string) bool {
+	if p.PackageJSON == nil {
+		return false
+	}
+	if _, ok := p.PackageJSON.Dependencies[name]; ok {
+		return true
+	}
+	_, ok := p.PackageJSON.DevDependencies[name]
+	return ok
+}
+
+func (p *Project) HasScript(name string) bool {
+	if p.PackageJSON == nil {
+		return false
+	}
+	_, ok := p.PackageJSON.Scripts[name]
+	return ok
+}
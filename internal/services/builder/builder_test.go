@@ -0,0 +1,96 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadProject(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"scripts":{"build":"vite build"},"devDependencies":{"vite":"^5"}}`), 0o644)
+
+	p, err := LoadProject(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.HasScript("build") || !p.HasDependency("vite") {
+		t.Errorf("unexpected package.json %+v", p.PackageJSON)
+	}
+
+	if p, err := LoadProject(t.TempDir()); err != nil || p.PackageJSON != nil {
+		t.Errorf("LoadProject without package.json = %+v, %v", p, err)
+	}
+}
+
+func TestLoadProjectRejectsUnsafePackageJSON(t *testing.T) {
+	parent := t.TempDir()
+	os.WriteFile(filepath.Join(parent, "host.json"), []byte(`{"scripts":{"build":"host"}}`), 0o644)
+
+	tests := []struct {
+		name  string
+		setup func(dir string) error
+	}{
+		{"symlink outside the clone", func(dir string) error {
+			return os.Symlink("../host.json", filepath.Join(dir, "package.json"))
+		}},
+		{"absolute symlink", func(dir string) error {
+			return os.Symlink(filepath.Join(parent, "host.json"), filepath.Join(dir, "package.json"))
+		}},
+		{"device", func(dir string) error {
+			return os.Symlink("/dev/zero", filepath.Join(dir, "package.json"))
+		}},
+		{"directory", func(dir string) error {
+			return os.Mkdir(filepath.Join(dir, "package.json"), 0o755)
+		}},
+		{"oversized", func(dir string) error {
+			return os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"x":"`+strings.Repeat("a", maxPackageJSONSize)+`"}`), 0o644)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := os.MkdirTemp(parent, "repo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.setup(dir); err != nil {
+				t.Fatal(err)
+			}
+			if p, err := LoadProject(dir); err == nil {
+				t.Errorf("LoadProject = %+v, want an error", p.PackageJSON)
+			}
+		})
+	}
+}
+
+func TestHugoDetection(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  bool
+	}{
+		{"hugo.toml", []string{"hugo.toml"}, true},
+		{"hugo.yaml", []string{"hugo.yaml"}, true},
+		{"config.toml with content", []string{"config.toml", "content/_index.md"}, true},
+		{"config.toml with layouts", []string{"config.toml", "layouts/index.html"}, true},
+		{"config.toml alone", []string{"config.toml"}, false},
+		{"config.toml with other dirs", []string{"config.toml", "src/main.rs"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755)
+				os.WriteFile(filepath.Join(dir, name), nil, 0o644)
+			}
+			if got := (hugoBuilder{}).Detect(&Project{Dir: dir}); got != tt.want {
+				t.Errorf("Detect = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if plan := (hugoBuilder{}).Plan(&Project{}); plan.Toolchain != ToolchainHugo {
+		t.Errorf("Hugo plan runs in toolchain %q", plan.Toolchain)
+	}
+}
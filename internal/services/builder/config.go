@@ -0,0 +1,181 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/utils"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	ConfigFileName    = "gopher.json"
+	FrameworkCustom   = "custom"
+	FrameworkPrebuilt = "prebuilt"
+
+	maxConfigSize = 1 << 20
+)
+
+// LoadConfig reads and validates gopher.json from the repository root.
+// It returns nil without error when the file does not exist.
+func LoadConfig(repoDir string) (*models.ProjectConfig, error) {
+	data, err := readFile(repoDir, ConfigFileName, maxConfigSize)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return ParseConfig(data)
+}
+
+func ParseConfig(data []byte) (*models.ProjectConfig, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var cfg models.ProjectConfig
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ConfigFileName, err)
+	}
+
+	if err := ValidateConfig(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ConfigFileName, err)
+	}
+
+	return &cfg, nil
+}
+
+// ValidateConfig checks settings from gopher.json or a project and reports
+// problems by their JSON field names.
+func ValidateConfig(cfg *models.ProjectConfig) error {
+	if err := utils.ValidateInput(cfg); err != nil {
+		return errors.New(formatValidationError(err))
+	}
+	return nil
+}
+
+// MergeConfig layers a repository's gopher.json over the build settings
+// stored on its project, field by field. Either argument may be nil.
+func MergeConfig(base, override *models.ProjectConfig) *models.ProjectConfig {
+	if base == nil {
+		return override
+	}
+	if override == nil {
+		return base
+	}
+
+	merged := *base
+	if override.InstallCommand != nil {
+		merged.InstallCommand = override.InstallCommand
+	}
+	if override.BuildCommand != nil {
+		merged.BuildCommand = override.BuildCommand
+	}
+	if override.OutputDirectory != "" {
+		merged.OutputDirectory = override.OutputDirectory
+	}
+	if override.RootDirectory != "" {
+		merged.RootDirectory = override.RootDirectory
+	}
+	if override.NodeVersion != "" {
+		merged.NodeVersion = override.NodeVersion
+	}
+	if override.Headers != nil {
+		merged.Headers = override.Headers
+	}
+	if override.Redirects != nil {
+		merged.Redirects = override.Redirects
+	}
+	if override.Rewrites != nil {
+		merged.Rewrites = override.Rewrites
+	}
+	return &merged
+}
+
+// ResolvePlan detects the framework in dir, inside the repository cloned to
+// repoDir, and applies overrides from cfg.
+// A config that specifies both a build command and an output directory is
+// enough to build projects that no detector recognises.
+func ResolvePlan(dir, repoDir string, cfg *models.ProjectConfig) (*Plan, error) {
+	plan, err := Detect(dir, repoDir)
+	if err != nil {
+		if cfg == nil || cfg.BuildCommand == nil || cfg.OutputDirectory == "" {
+			return nil, err
+		}
+		plan = &Plan{Framework: FrameworkCustom}
+	}
+
+	if cfg == nil {
+		return plan, nil
+	}
+
+	if cfg.InstallCommand != nil {
+		plan.Install = shellCommand(*cfg.InstallCommand)
+	}
+	if cfg.BuildCommand != nil {
+		plan.Build = shellCommand(*cfg.BuildCommand)
+	}
+	if cfg.OutputDirectory != "" {
+		plan.OutputDir = cfg.OutputDirectory
+	}
+
+	return plan, nil
+}
+
+// PrebuiltPlan publishes an uploaded directory as it is: the output
+// directory from cfg, or the project directory itself.
+func PrebuiltPlan(cfg *models.ProjectConfig) *Plan {
+	plan := &Plan{Framework: FrameworkPrebuilt, OutputDir: "."}
+	if cfg != nil && cfg.OutputDirectory != "" {
+		plan.OutputDir = cfg.OutputDirectory
+	}
+	return plan
+}
+
+// shellCommand runs a user-supplied command line through sh; an empty
+// command disables the step.
+func shellCommand(command string) []string {
+	if strings.TrimSpace(command) == "" {
+		return nil
+	}
+	return []string{"sh", "-c", command}
+}
+
+func formatValidationError(err error) string {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err.Error()
+	}
+
+	msgs := make([]string, 0, len(verrs))
+	for _, fe := range verrs {
+		field := fe.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+
+		switch fe.Tag() {
+		case "required":
+			msgs = append(msgs, fmt.Sprintf("%s is required", field))
+		case "startswith":
+			msgs = append(msgs, fmt.Sprintf("%s must start with %q", field, fe.Param()))
+		case "relpath":
+			msgs = append(msgs, fmt.Sprintf("%s must be a relative path inside the repository", field))
+		case "oneof":
+			msgs = append(msgs, fmt.Sprintf("%s must be one of %s", field, fe.Param()))
+		case "min", "max":
+			msgs = append(msgs, fmt.Sprintf("%s must have %s %s", field, fe.Tag(), fe.Param()))
+		default:
+			msgs = append(msgs, fmt.Sprintf("%s is invalid (%s)", field, fe.Tag()))
+		}
+	}
+
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,57 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	repo := t.TempDir()
+	if cfg, err := LoadConfig(repo); cfg != nil || err != nil {
+		t.Fatalf("LoadConfig without gopher.json = %+v, %v", cfg, err)
+	}
+
+	os.WriteFile(filepath.Join(repo, ConfigFileName), []byte(`{"outputDirectory":"public","nodeVersion":"20"}`), 0o644)
+	cfg, err := LoadConfig(repo)
+	if err != nil || cfg.OutputDirectory != "public" || cfg.NodeVersion != "20" {
+		t.Fatalf("LoadConfig = %+v, %v", cfg, err)
+	}
+
+	os.WriteFile(filepath.Join(repo, ConfigFileName), []byte(`{"outputDirectory":"../etc"}`), 0o644)
+	if _, err := LoadConfig(repo); err == nil {
+		t.Error("accepted an output directory outside the project")
+	}
+}
+
+func TestLoadConfigRejectsUnsafeFiles(t *testing.T) {
+	parent := t.TempDir()
+	os.WriteFile(filepath.Join(parent, "host.json"), []byte(`{}`), 0o644)
+
+	tests := []struct {
+		name  string
+		setup func(path string) error
+	}{
+		{"symlink outside the clone", func(path string) error { return os.Symlink("../host.json", path) }},
+		{"absolute symlink", func(path string) error { return os.Symlink(filepath.Join(parent, "host.json"), path) }},
+		{"device", func(path string) error { return os.Symlink("/dev/zero", path) }},
+		{"oversized", func(path string) error {
+			return os.WriteFile(path, []byte(`{"nodeVersion":"`+strings.Repeat(" ", maxConfigSize)+`"}`), 0o644)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, err := os.MkdirTemp(parent, "repo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.setup(filepath.Join(repo, ConfigFileName)); err != nil {
+				t.Fatal(err)
+			}
+			if cfg, err := LoadConfig(repo); err == nil {
+				t.Errorf("LoadConfig = %+v, want an error", cfg)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package builder
+
+import "path/filepath"
+
+const (
+	FrameworkNext   = "nextjs"
+	FrameworkAstro  = "astro"
+	FrameworkVite   = "vite"
+	FrameworkCRA    = "create-react-app"
+	FrameworkHugo   = "hugo"
+	FrameworkNode   = "node"
+	FrameworkStatic = "static"
+)
+
+// packagePlan installs dependencies and runs the build script with the
+// project's package manager. Projects in a workspace install from its root,
+// where the lockfile covering every package lives.
+func packagePlan(p *Project, framework, outputDir string) *Plan {
+	installFrom, installDir := p, ""
+	if p.Workspace != nil {
+		installFrom = p.Workspace
+		if rel, err := filepath.Rel(p.Dir, p.Workspace.Dir); err == nil {
+			installDir = filepath.ToSlash(rel)
+		}
+	}
+
+	pm := DetectPackageManager(installFrom)
+	return &Plan{
+		Framework:      framework,
+		Install:        pm.Install(),
+		Build:          pm.Run("build"),
+		OutputDir:      outputDir,
+		PackageManager: pm,
+		InstallDir:     installDir,
+	}
+}
+
+// nextBuilder expects the project to use static export (output: 'export').
+type nextBuilder struct{}
+
+func (nextBuilder) Name() string { return FrameworkNext }
+
+func (nextBuilder) Detect(p *Project) bool {
+	return p.HasDependency("next")
+}
+
+func (nextBuilder) Plan(p *Project) *Plan {
+	return packagePlan(p, FrameworkNext, "out")
+}
+
+type astroBuilder struct{}
+
+func (astroBuilder) Name() string { return FrameworkAstro }
+
+func (astroBuilder) Detect(p *Project) bool {
+	return p.HasDependency("astro") || p.HasFile("astro.config.mjs", "astro.config.js", "astro.config.ts")
+}
+
+func (astroBuilder) Plan(p *Project) *Plan {
+	return packagePlan(p, FrameworkAstro, "dist")
+}
+
+type viteBuilder struct{}
+
+func (viteBuilder) Name() string { return FrameworkVite }
+
+func (viteBuilder) Detect(p *Project) bool {
+	return p.HasDependency("vite") || p.HasFile("vite.config.js", "vite.config.ts", "vite.config.mjs")
+}
+
+func (viteBuilder) Plan(p *Project) *Plan {
+	return packagePlan(p, FrameworkVite, "dist")
+}
+
+type craBuilder struct{}
+
+func (craBuilder) Name() string { return FrameworkCRA }
+
+func (craBuilder) Detect(p *Project) bool {
+	return p.HasDependency("react-scripts")
+}
+
+func (craBuilder) Plan(p *Project) *Plan {
+	return packagePlan(p, FrameworkCRA, "build")
+}
+
+type hugoBuilder struct{}
+
+func (hugoBuilder) Name() string { return FrameworkHugo }
+
+func (hugoBuilder) Detect(p *Project) bool {
+	if p.PackageJSON != nil {
+		return false
+	}
+	if p.HasFile("hugo.toml", "hugo.yaml", "hugo.json") {
+		return true
+	}
+	// Older sites use config.toml, which is too common to go by on its own
+	return p.HasFile("config.toml") && p.HasFile("content", "layouts")
+}
+
+func (hugoBuilder) Plan(p *Project) *Plan {
+	return &Plan{
+		Framework: FrameworkHugo,
+		Build:     []string{"hugo", "--minify"},
+		OutputDir: "public",
+		Toolchain: ToolchainHugo,
+	}
+}
+
+// nodeBuilder is the generic fallback for any package.json with a build script.
+type nodeBuilder struct{}
+
+func (nodeBuilder) Name() string { return FrameworkNode }
+
+func (nodeBuilder) Detect(p *Project) bool {
+	return p.HasScript("build")
+}
+
+func (nodeBuilder) Plan(p *Project) *Plan {
+	return packagePlan(p, FrameworkNode, "dist")
+}
+
+// staticBuilder serves repositories that already contain plain HTML.
+type staticBuilder struct{}
+
+func (staticBuilder) Name() string { return FrameworkStatic }
+
+func (staticBuilder) Detect(p *Project) bool {
+	return p.HasFile("index.html")
+}
+
+func (staticBuilder) Plan(p *Project) *Plan {
+	return &Plan{
+		Framework: FrameworkStatic,
+		OutputDir: ".",
+	}
+}
@@ -0,0 +1,41 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// readFile reads a regular file in dir through an os.Root, so a symlink
+// committed to the repository can't lead outside it, and refuses files
+// larger than limit. A missing file is reported with fs.ErrNotExist.
+func readFile(dir, name string, limit int64) ([]byte, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	f, err := root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", name)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s exceeds %d bytes", name, limit)
+	}
+	return data, nil
+}
@@ -0,0 +1,235 @@
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedNode is returned when a project asks for a Node.js version
+// the worker has no toolchain for.
+var ErrUnsupportedNode = errors.New("unsupported Node.js version")
+
+// maxVersionFileSize bounds .nvmrc and .node-version, which hold one line.
+const maxVersionFileSize = 1 << 10
+
+// nodeCodenames maps LTS release names, as used in .nvmrc, to majors.
+var nodeCodenames = map[string]int{
+	"argon":    4,
+	"boron":    6,
+	"carbon":   8,
+	"dubnium":  10,
+	"erbium":   12,
+	"fermium":  14,
+	"gallium":  16,
+	"hydrogen": 18,
+	"iron":     20,
+	"jod":      22,
+	"krypton":  24,
+}
+
+// comparatorPattern matches one semver comparator such as ">=18.17", "^20"
+// or "v22.x".
+var comparatorPattern = regexp.MustCompile(`^(>=|<=|>|<|=|\^|~)?\s*v?(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?(?:[-+][0-9A-Za-z.-]*)?$`)
+
+// NodeVersionSpec finds the Node.js version a project asks for in .nvmrc,
+// .node-version or the engines field of package.json, looking in dir and
+// then in repoDir for monorepos. It returns the spec and the file it came
+// from, or empty strings when the project doesn't say.
+func NodeVersionSpec(dir, repoDir string) (spec, source string) {
+	dirs := []string{dir}
+	if repoDir != dir {
+		dirs = append(dirs, repoDir)
+	}
+	for _, d := range dirs {
+		for _, name := range []string{".nvmrc", ".node-version"} {
+			if spec := readVersionFile(d, name); spec != "" {
+				return spec, name
+			}
+		}
+		p, err := LoadProject(d)
+		if err == nil && p.PackageJSON != nil && strings.TrimSpace(p.PackageJSON.Engines["node"]) != "" {
+			return strings.TrimSpace(p.PackageJSON.Engines["node"]), "package.json engines.node"
+		}
+	}
+	return "", ""
+}
+
+// readVersionFile returns the first non-comment line of a version file in
+// dir, or an empty string when it is missing or not a small regular file.
+func readVersionFile(dir, name string) string {
+	data, err := readFile(dir, name, maxVersionFileSize)
+	if err != nil {
+		return ""
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// ResolveNodeVersion picks the newest supported major that satisfies spec:
+// an exact version, a semver range as in engines.node, or an nvm alias such
+// as "lts/*" or "node". An empty spec resolves to fallback.
+func ResolveNodeVersion(spec string, supported []string, fallback string) (string, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return fallback, nil
+	}
+
+	majors := make([]int, 0, len(supported))
+	for _, v := range supported {
+		if n, err := strconv.Atoi(v); err == nil {
+			majors = append(majors, n)
+		}
+	}
+	slices.Sort(majors)
+	slices.Reverse(majors)
+
+	match, err := nodeMatcher(strings.ToLower(spec))
+	if err != nil {
+		return "", fmt.Errorf("%w %s: %v", ErrUnsupportedNode, quoteSpec(spec), err)
+	}
+	for _, major := range majors {
+		if match(major) {
+			return strconv.Itoa(major), nil
+		}
+	}
+	return "", fmt.Errorf("%w %s, supported versions are %s", ErrUnsupportedNode, quoteSpec(spec), strings.Join(supported, ", "))
+}
+
+// quoteSpec shortens and quotes a version spec for error messages, which
+// users can read: the spec may come from any file in the repository.
+func quoteSpec(spec string) string {
+	const maxLen = 32
+	if len(spec) > maxLen {
+		spec = spec[:maxLen] + "..."
+	}
+	return strconv.QuoteToASCII(spec)
+}
+
+// nodeMatcher turns a version spec into a predicate on major versions.
+// Minor and patch constraints are ignored since a toolchain is provided
+// per major, except where they move a bound to the next major.
+func nodeMatcher(spec string) (func(int) bool, error) {
+	switch {
+	case spec == "node" || spec == "latest" || spec == "current" || spec == "stable":
+		return func(int) bool { return true }, nil
+	case spec == "lts/*" || spec == "lts":
+		return func(major int) bool { return major%2 == 0 }, nil
+	case strings.HasPrefix(spec, "lts/"):
+		major, ok := nodeCodenames[strings.TrimPrefix(spec, "lts/")]
+		if !ok {
+			return nil, errors.New("unknown LTS codename")
+		}
+		return func(m int) bool { return m == major }, nil
+	}
+
+	var alternatives []func(int) bool
+	for _, alt := range strings.Split(spec, "||") {
+		match, err := rangeMatcher(strings.TrimSpace(alt))
+		if err != nil {
+			return nil, err
+		}
+		alternatives = append(alternatives, match)
+	}
+	return func(major int) bool {
+		return slices.ContainsFunc(alternatives, func(match func(int) bool) bool { return match(major) })
+	}, nil
+}
+
+// rangeMatcher handles a space-separated set of comparators or a hyphen range.
+func rangeMatcher(r string) (func(int) bool, error) {
+	if lo, hi, ok := strings.Cut(r, " - "); ok {
+		low, err := comparatorMatcher(">=" + strings.TrimSpace(lo))
+		if err != nil {
+			return nil, err
+		}
+		high, err := comparatorMatcher("<=" + strings.TrimSpace(hi))
+		if err != nil {
+			return nil, err
+		}
+		return func(m int) bool { return low(m) && high(m) }, nil
+	}
+
+	// Allow ">= 18" as well as ">=18"
+	fields := strings.Fields(r)
+	var comparators []string
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if strings.Trim(f, "<>=^~") == "" && i+1 < len(fields) {
+			f += fields[i+1]
+			i++
+		}
+		comparators = append(comparators, f)
+	}
+
+	var all []func(int) bool
+	for _, c := range comparators {
+		match, err := comparatorMatcher(c)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, match)
+	}
+	return func(major int) bool {
+		for _, match := range all {
+			if !match(major) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func comparatorMatcher(c string) (func(int) bool, error) {
+	if c == "" || c == "*" || c == "x" {
+		return func(int) bool { return true }, nil
+	}
+	m := comparatorPattern.FindStringSubmatch(c)
+	if m == nil {
+		return nil, fmt.Errorf("cannot parse %s", quoteSpec(c))
+	}
+	op := m[1]
+	if m[2] == "x" || m[2] == "*" {
+		return func(int) bool { return true }, nil
+	}
+	v, _ := strconv.Atoi(m[2])
+	// Whether the version goes beyond the start of its major, e.g. 18.17
+	partial := (m[3] != "" && m[3] != "0" && !isWildcard(m[3])) ||
+		(m[4] != "" && m[4] != "0" && !isWildcard(m[4]))
+
+	switch op {
+	case ">=":
+		return func(major int) bool { return major >= v }, nil
+	case ">":
+		if m[3] == "" || isWildcard(m[3]) {
+			return func(major int) bool { return major > v }, nil
+		}
+		return func(major int) bool { return major >= v }, nil
+	case "<=":
+		return func(major int) bool { return major <= v }, nil
+	case "<":
+		if partial {
+			return func(major int) bool { return major <= v }, nil
+		}
+		return func(major int) bool { return major < v }, nil
+	default:
+		// =, ^, ~ and bare versions stay within the major
+		return func(major int) bool { return major == v }, nil
+	}
+}
+
+func isWildcard(s string) bool {
+	return s == "x" || s == "X" || s == "*"
+}
@@ -0,0 +1,78 @@
+package builder
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNodeVersionSpecStaysInsideRepository(t *testing.T) {
+	parent := t.TempDir()
+	secret := filepath.Join(parent, "environ")
+	os.WriteFile(secret, []byte("SECRET_TOKEN=hunter2\n"), 0o644)
+
+	repo := filepath.Join(parent, "repo")
+	os.Mkdir(repo, 0o755)
+	if err := os.Symlink("../environ", filepath.Join(repo, ".nvmrc")); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(repo, ".node-version"), []byte("# pinned\n20\n"), 0o644)
+
+	spec, source := NodeVersionSpec(repo, repo)
+	if spec != "20" || source != ".node-version" {
+		t.Errorf("NodeVersionSpec = %q from %q, want 20 from .node-version", spec, source)
+	}
+}
+
+func TestNodeVersionSpecIgnoresOversizedFiles(t *testing.T) {
+	repo := t.TempDir()
+	os.WriteFile(filepath.Join(repo, ".nvmrc"), []byte(strings.Repeat("#", maxVersionFileSize)+"\n20\n"), 0o644)
+
+	if spec, _ := NodeVersionSpec(repo, repo); spec != "" {
+		t.Errorf("NodeVersionSpec read %q from an oversized .nvmrc", spec)
+	}
+}
+
+func TestResolveNodeVersionErrorsShortenSpec(t *testing.T) {
+	spec := "SECRET_TOKEN=hunter2 " + strings.Repeat("A", 100) + "\x1b[2J"
+	_, err := ResolveNodeVersion(spec, []string{"18", "20"}, "20")
+	if !errors.Is(err, ErrUnsupportedNode) {
+		t.Fatalf("ResolveNodeVersion = %v, want ErrUnsupportedNode", err)
+	}
+	if strings.Contains(err.Error(), strings.Repeat("A", 40)) || strings.Contains(err.Error(), "\x1b") {
+		t.Errorf("error carries the raw spec: %q", err)
+	}
+}
+
+func TestResolveNodeVersion(t *testing.T) {
+	supported := []string{"18", "20", "22"}
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"", "20"},
+		{"20", "20"},
+		{"v18.17.0", "18"},
+		{">=18", "22"},
+		{"^20.5", "20"},
+		{">=18 <21", "20"},
+		{"18 || 20", "20"},
+		{"lts/hydrogen", "18"},
+		{"lts/*", "22"},
+		{"node", "22"},
+	}
+	for _, tt := range tests {
+		got, err := ResolveNodeVersion(tt.spec, supported, "20")
+		if err != nil || got != tt.want {
+			t.Errorf("ResolveNodeVersion(%q) = %q, %v; want %q", tt.spec, got, err, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"16", "<18", "lts/unknown", "banana"} {
+		if _, err := ResolveNodeVersion(spec, supported, "20"); !errors.Is(err, ErrUnsupportedNode) {
+			t.Errorf("ResolveNodeVersion(%q) = %v, want ErrUnsupportedNode", spec, err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package builder
+
+import (
+	"bufio"
+	"strings"
+)
+
+const (
+	PackageManagerNPM  = "npm"
+	PackageManagerYarn = "yarn"
+	PackageManagerPNPM = "pnpm"
+	PackageManagerBun  = "bun"
+)
+
+// Lockfiles map each lockfile to its package manager, in detection order.
+var Lockfiles = []struct {
+	Name    string
+	Manager string
+}{
+	{"pnpm-lock.yaml", PackageManagerPNPM},
+	{"yarn.lock", PackageManagerYarn},
+	{"bun.lock", PackageManagerBun},
+	{"bun.lockb", PackageManagerBun},
+	{"package-lock.json", PackageManagerNPM},
+	{"npm-shrinkwrap.json", PackageManagerNPM},
+}
+
+// PackageManager installs a project's dependencies and runs its scripts.
+type PackageManager struct {
+	Name     string
+	Version  string // pinned by package.json's packageManager field, empty otherwise
+	Lockfile string // empty when the project has none
+	Berry    bool   // Yarn 2 or later
+}
+
+// DetectPackageManager picks the package manager a project was developed
+// with: the Corepack packageManager pin first, then the lockfile, else npm.
+func DetectPackageManager(p *Project) *PackageManager {
+	pm := &PackageManager{Name: PackageManagerNPM}
+	for _, lf := range Lockfiles {
+		if p.HasFile(lf.Name) {
+			pm.Name, pm.Lockfile = lf.Manager, lf.Name
+			break
+		}
+	}
+
+	if p.PackageJSON != nil && p.PackageJSON.PackageManager != "" {
+		// e.g. "pnpm@9.1.0+sha512.abc"
+		name, version, _ := strings.Cut(p.PackageJSON.PackageManager, "@")
+		version, _, _ = strings.Cut(version, "+")
+		switch name {
+		case PackageManagerNPM, PackageManagerYarn, PackageManagerPNPM, PackageManagerBun:
+			if name != pm.Name {
+				// A lockfile of another manager can't be installed frozen
+				pm.Lockfile = ""
+				for _, lf := range Lockfiles {
+					if lf.Manager == name && p.HasFile(lf.Name) {
+						pm.Lockfile = lf.Name
+						break
+					}
+				}
+			}
+			pm.Name, pm.Version = name, version
+		}
+	}
+
+	if pm.Name == PackageManagerYarn {
+		pm.Berry = p.HasFile(".yarnrc.yml")
+		if pm.Version != "" {
+			pm.Berry = !strings.HasPrefix(pm.Version, "1.")
+		}
+	}
+	return pm
+}
+
+// Install installs dependencies exactly as locked when there is a lockfile.
+func (pm *PackageManager) Install() []string {
+	args := []string{"install"}
+	if pm.Lockfile != "" {
+		switch {
+		case pm.Name == PackageManagerNPM:
+			args = []string{"ci"}
+		case pm.Name == PackageManagerYarn && pm.Berry:
+			args = append(args, "--immutable")
+		default:
+			args = append(args, "--frozen-lockfile")
+		}
+	}
+	return pm.command(args...)
+}
+
+// Run runs a package.json script.
+func (pm *PackageManager) Run(script string) []string {
+	return pm.command("run", script)
+}
+
+// managerScript runs the package manager named by $0 (optionally as
+// name@version) with the remaining arguments. Yarn and pnpm come from
+// Corepack, which honours the packageManager pin, and Bun is installed from
+// npm unless the image has it. Shims go to a temporary directory because
+// the build sandbox's root filesystem is read-only. The version line is
+// parsed back by UsedVersion.
+const managerScript = `set -e
+pm=${0%%@*}
+version=
+case "$0" in *@*) version=${0#*@} ;; esac
+export COREPACK_ENABLE_DOWNLOAD_PROMPT=0
+case "$pm" in
+yarn|pnpm)
+	bin=$(mktemp -d)
+	corepack enable --install-directory "$bin" "$pm"
+	PATH="$bin:$PATH"
+	;;
+bun)
+	if ! command -v bun >/dev/null 2>&1; then
+		bin=$(mktemp -d)
+		npm install --global --silent --prefix "$bin" "bun@${version:-latest}"
+		PATH="$bin/bin:$PATH"
+	fi
+	;;
+esac
+echo "Using $pm $("$pm" --version)"
+exec "$pm" "$@"`
+
+func (pm *PackageManager) command(args ...string) []string {
+	name := pm.Name
+	if pm.Name == PackageManagerBun && pm.Version != "" {
+		name += "@" + pm.Version
+	}
+	return append([]string{"sh", "-c", managerScript, name}, args...)
+}
+
+// FormatCommand renders a build command for display, showing package
+// manager commands as they would be typed.
+func FormatCommand(cmd []string) string {
+	if len(cmd) > 3 && cmd[0] == "sh" && cmd[1] == "-c" && cmd[2] == managerScript {
+		name, _, _ := strings.Cut(cmd[3], "@")
+		cmd = append([]string{name}, cmd[4:]...)
+	}
+	return strings.Join(cmd, " ")
+}
+
+// UsedVersion finds the version a build step reported for the package
+// manager, empty when the step didn't run it.
+func UsedVersion(output, manager string) string {
+	prefix := "Using " + manager + " "
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		if version, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), prefix); ok {
+			return strings.TrimSpace(version)
+		}
+	}
+	return ""
+}
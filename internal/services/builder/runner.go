@@ -0,0 +1,61 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	"deployment-platform/internal/config"
+)
+
+const (
+	RunnerLocal     = "local"
+	RunnerContainer = "container"
+)
+
+// ToolchainHugo runs a step in BUILD_HUGO_IMAGE instead of the Node.js image.
+const ToolchainHugo = "hugo"
+
+// RunSpec describes a single build step.
+type RunSpec struct {
+	ID        string   // unique per step, used to name sandboxes
+	Workspace string   // host directory holding the cloned repository
+	Dir       string   // working directory relative to Workspace
+	Command   []string // argv, never interpreted by a host shell
+	Env       []string // KEY=VALUE pairs exposed to the step
+	CacheDir  string   // directory relative to Workspace for package manager caches, empty for their defaults
+	Node      string   // Node.js major to run with, empty for the default
+	Toolchain string   // Plan.Toolchain, empty for Node.js
+}
+
+// cacheEnv points npm, yarn, pnpm, bun and Corepack at dir so their
+// download caches end up in the workspace, where they can be saved between builds.
+func cacheEnv(dir string) []string {
+	return []string{
+		"COREPACK_HOME=" + dir + "/corepack",
+		"npm_config_cache=" + dir + "/npm",
+		"YARN_CACHE_FOLDER=" + dir + "/yarn",
+		"npm_config_store_dir=" + dir + "/pnpm",
+		"BUN_INSTALL_CACHE_DIR=" + dir + "/bun",
+	}
+}
+
+// BuildRunner executes build steps for untrusted repositories, writing
+// combined stdout and stderr to output.
+type BuildRunner interface {
+	Name() string
+	Run(ctx context.Context, spec RunSpec, output io.Writer) error
+}
+
+func NewRunner(cfg *config.Config) (BuildRunner, error) {
+	switch cfg.BuildRunner {
+	case RunnerLocal:
+		log.Println("WARNING: builds run unsandboxed on this host (BUILD_RUNNER=local)")
+		return NewLocalRunner(), nil
+	case RunnerContainer:
+		return NewContainerRunner(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown build runner %q", cfg.BuildRunner)
+	}
+}
@@ -0,0 +1,149 @@
+package builder
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"deployment-platform/internal/config"
+)
+
+const containerWorkspace = "/workspace"
+
+// envPrelude exports the variables written to stdin as KEY=<base64 value>
+// lines, then runs the step. Project variables travel this way rather than
+// as --env flags, which any user on the host could read with ps and which
+// docker inspect would keep showing afterwards.
+const envPrelude = `while IFS= read -r line; do
+	value=$(printf %s "${line#*=}" | base64 -d; echo x)
+	export "${line%%=*}=${value%x}"
+done
+exec "$@"`
+
+// ContainerRunner executes each step in a throwaway container through a
+// Docker-compatible CLI (docker, or podman for rootless operation). Only the
+// workspace is mounted, the environment starts empty and resources are capped,
+// so builds cannot reach the platform's own credentials.
+type ContainerRunner struct {
+	runtime   string
+	image     string // {version} is replaced with the Node.js major
+	hugoImage string
+	node      string // default Node.js major
+	network   string
+	cpus      string
+	memory    string
+	pidsLimit string
+	diskSize  string
+	tmpSize   string
+}
+
+func NewContainerRunner(cfg *config.Config) *ContainerRunner {
+	return &ContainerRunner{
+		runtime:   cfg.BuildRuntime,
+		image:     cfg.BuildImage,
+		hugoImage: cfg.BuildHugoImage,
+		node:      cfg.BuildNodeDefault,
+		network:   cfg.BuildNetwork,
+		cpus:      cfg.BuildCPUs,
+		memory:    cfg.BuildMemory,
+		pidsLimit: cfg.BuildPidsLimit,
+		diskSize:  cfg.BuildDiskSize,
+		tmpSize:   cfg.BuildTmpSize,
+	}
+}
+
+func (r *ContainerRunner) Name() string { return RunnerContainer }
+
+func (r *ContainerRunner) Run(ctx context.Context, spec RunSpec, output io.Writer) error {
+	cmd := exec.CommandContext(ctx, r.runtime, r.args(spec)...)
+	// The runtime CLI itself gets no inherited secrets either
+	cmd.Env = []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"}
+	cmd.Stdin = envInput(spec.Env)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	// Killing the CLI client does not stop the container, so ask the runtime
+	cmd.Cancel = func() error {
+		kill := exec.Command(r.runtime, "kill", containerName(spec.ID))
+		kill.Env = cmd.Env
+		kill.Run()
+		return cmd.Process.Kill()
+	}
+	cmd.WaitDelay = 10 * time.Second
+
+	return cmd.Run()
+}
+
+func (r *ContainerRunner) args(spec RunSpec) []string {
+	args := []string{
+		"run", "--rm",
+		"--name", containerName(spec.ID),
+		"--network", r.network,
+		"--cpus", r.cpus,
+		"--memory", r.memory,
+		"--memory-swap", r.memory,
+		"--pids-limit", r.pidsLimit,
+		"--cap-drop", "ALL",
+		"--security-opt", "no-new-privileges",
+		"--read-only",
+		"--tmpfs", "/tmp:rw,exec,size=" + r.tmpSize,
+		"--volume", spec.Workspace + ":" + containerWorkspace + ":rw",
+		"--workdir", path.Join(containerWorkspace, spec.Dir),
+		// Run the step itself as the user owning the workspace, whatever
+		// entrypoint and user the image declares
+		"--entrypoint", "",
+		"--user", "0:0",
+		"--env", "HOME=/tmp",
+		"--env", "CI=true",
+	}
+	if r.diskSize != "" {
+		// Requires a storage driver with quota support (e.g. overlay on xfs)
+		args = append(args, "--storage-opt", "size="+r.diskSize)
+	}
+	if spec.CacheDir != "" {
+		for _, env := range cacheEnv(path.Join(containerWorkspace, spec.CacheDir)) {
+			args = append(args, "--env", env)
+		}
+	}
+	if len(spec.Env) > 0 {
+		args = append(args, "--interactive", r.imageFor(spec), "sh", "-c", envPrelude, "sh")
+	} else {
+		args = append(args, r.imageFor(spec))
+	}
+	return append(args, spec.Command...)
+}
+
+// envInput encodes env for envPrelude; base64 keeps multi-line values intact.
+func envInput(env []string) io.Reader {
+	if len(env) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	for _, kv := range env {
+		key, value, _ := strings.Cut(kv, "=")
+		b.WriteString(key + "=" + base64.StdEncoding.EncodeToString([]byte(value)) + "\n")
+	}
+	return strings.NewReader(b.String())
+}
+
+// imageFor returns the build image for a step's toolchain or Node.js major.
+// The runtime pulls each image once and keeps it, so every supported
+// toolchain stays cached on the worker.
+func (r *ContainerRunner) imageFor(spec RunSpec) string {
+	if spec.Toolchain == ToolchainHugo {
+		return r.hugoImage
+	}
+	node := spec.Node
+	if node == "" {
+		node = r.node
+	}
+	return strings.ReplaceAll(r.image, "{version}", node)
+}
+
+func containerName(id string) string {
+	return "gopher-build-" + id
+}
@@ -0,0 +1,44 @@
+package builder
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// LocalRunner executes steps directly on the host with the process
+// environment. It offers no isolation and is meant for development only,
+// and always uses the host's Node.js whatever version a project asks for.
+type LocalRunner struct{}
+
+func NewLocalRunner() *LocalRunner {
+	return &LocalRunner{}
+}
+
+func (r *LocalRunner) Name() string { return RunnerLocal }
+
+func (r *LocalRunner) Run(ctx context.Context, spec RunSpec, output io.Writer) error {
+	cmd := exec.CommandContext(ctx, spec.Command[0], spec.Command[1:]...)
+	cmd.Dir = filepath.Join(spec.Workspace, spec.Dir)
+	cmd.Env = os.Environ()
+	if spec.CacheDir != "" {
+		cmd.Env = append(cmd.Env, cacheEnv(filepath.Join(spec.Workspace, spec.CacheDir))...)
+	}
+	cmd.Env = append(cmd.Env, spec.Env...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	// Run in a dedicated process group so cancellation also stops the
+	// scripts npm spawns, not just npm itself
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = 10 * time.Second
+
+	return cmd.Run()
+}
@@ -0,0 +1,78 @@
+package builder
+
+import (
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// pnpmWorkspaceFile marks the root of a pnpm workspace.
+const pnpmWorkspaceFile = "pnpm-workspace.yaml"
+
+// WorkspacePatterns returns the package globs of an npm or yarn workspace
+// root, which package.json lists either directly or under "packages".
+func (pkg *PackageJSON) WorkspacePatterns() []string {
+	if len(pkg.Workspaces) == 0 {
+		return nil
+	}
+	var patterns []string
+	if err := json.Unmarshal(pkg.Workspaces, &patterns); err == nil {
+		return patterns
+	}
+	var nested struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(pkg.Workspaces, &nested); err == nil {
+		return nested.Packages
+	}
+	return nil
+}
+
+// FindWorkspace returns the npm, yarn or pnpm workspace that the project in
+// dir belongs to, searching the directories above it up to repoDir, or nil
+// when the project stands alone.
+func FindWorkspace(dir, repoDir string) (*Project, error) {
+	dir, repoDir = filepath.Clean(dir), filepath.Clean(repoDir)
+	for d := dir; d != repoDir; {
+		parent := filepath.Dir(d)
+		if parent == d || !strings.HasPrefix(parent, repoDir) {
+			return nil, nil
+		}
+		d = parent
+
+		rel, err := filepath.Rel(d, dir)
+		if err != nil {
+			return nil, err
+		}
+		root, err := LoadProject(d)
+		if err != nil {
+			return nil, err
+		}
+		if root.HasFile(pnpmWorkspaceFile) {
+			return root, nil
+		}
+		if root.PackageJSON != nil && matchesWorkspace(root.PackageJSON.WorkspacePatterns(), filepath.ToSlash(rel)) {
+			return root, nil
+		}
+	}
+	return nil, nil
+}
+
+// matchesWorkspace reports whether a package directory, relative to the
+// workspace root, is covered by one of the workspace globs.
+func matchesWorkspace(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(strings.TrimSuffix(pattern, "/"), "./")
+		if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if rel == prefix || strings.HasPrefix(rel, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
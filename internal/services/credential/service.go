@@ -0,0 +1,169 @@
+package credential
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/secrets"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCredentialNotFound = errors.New("git credential not found")
+	ErrNameTaken          = errors.New("a git credential with this name already exists")
+)
+
+// ValidationError is returned for input the client has to fix.
+type ValidationError struct {
+	msg string
+}
+
+func (e *ValidationError) Error() string { return e.msg }
+
+// CreateCredentialInput describes a new credential. Token is required for
+// token credentials; SSH credentials get a freshly generated key pair.
+type CreateCredentialInput struct {
+	Name     string
+	Type     string
+	Username string
+	Token    string
+}
+
+type Service interface {
+	CreateCredential(ctx context.Context, userID uint, input CreateCredentialInput) (*models.GitCredential, error)
+	GetCredential(ctx context.Context, credentialID, userID uint) (*models.GitCredential, error)
+	ListCredentials(ctx context.Context, userID uint) ([]models.GitCredential, error)
+	DeleteCredential(ctx context.Context, credentialID, userID uint) error
+}
+
+type service struct {
+	db  *gorm.DB
+	box *secrets.Box
+}
+
+func NewService(db *gorm.DB, box *secrets.Box) Service {
+	return &service{
+		db:  db,
+		box: box,
+	}
+}
+
+func (s *service) CreateCredential(ctx context.Context, userID uint, input CreateCredentialInput) (*models.GitCredential, error) {
+	cred := &models.GitCredential{
+		UserID:   userID,
+		Name:     strings.TrimSpace(input.Name),
+		Type:     input.Type,
+		Username: input.Username,
+	}
+
+	var secret string
+	switch input.Type {
+	case models.CredentialToken:
+		if input.Token == "" {
+			return nil, &ValidationError{msg: "token is required for token credentials"}
+		}
+		secret = input.Token
+	case models.CredentialSSH:
+		if input.Token != "" {
+			return nil, &ValidationError{msg: "SSH keys are generated by the platform, token must be empty"}
+		}
+		privateKey, publicKey, fingerprint, err := generateDeployKey(cred.Name)
+		if err != nil {
+			return nil, err
+		}
+		secret = privateKey
+		cred.PublicKey = publicKey
+		cred.Fingerprint = fingerprint
+	default:
+		return nil, &ValidationError{msg: fmt.Sprintf("type must be %q or %q", models.CredentialToken, models.CredentialSSH)}
+	}
+
+	sealed, err := s.box.Seal(secret)
+	if err != nil {
+		return nil, err
+	}
+	cred.Secret = sealed
+
+	if err := s.db.Create(cred).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrNameTaken
+		}
+		return nil, err
+	}
+	return cred, nil
+}
+
+func (s *service) GetCredential(ctx context.Context, credentialID, userID uint) (*models.GitCredential, error) {
+	var cred models.GitCredential
+	if err := s.db.Where("id = ? AND user_id = ?", credentialID, userID).First(&cred).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCredentialNotFound
+		}
+		return nil, err
+	}
+	return &cred, nil
+}
+
+func (s *service) ListCredentials(ctx context.Context, userID uint) ([]models.GitCredential, error) {
+	var creds []models.GitCredential
+	if err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&creds).Error; err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// DeleteCredential removes a credential and detaches it from the user's
+// projects. Deployments still queued with it fail to clone.
+func (s *service) DeleteCredential(ctx context.Context, credentialID, userID uint) error {
+	cred, err := s.GetCredential(ctx, credentialID, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Project{}).
+			Where("user_id = ? AND credential_id = ?", userID, cred.ID).
+			Update("credential_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(cred).Error
+	})
+}
+
+// generateDeployKey returns an ed25519 key pair: the private key in OpenSSH
+// PEM format, the public key in authorized_keys format and its fingerprint.
+func generateDeployKey(comment string) (string, string, string, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", "", err
+	}
+	block, err := ssh.MarshalPrivateKey(priv, comment)
+	if err != nil {
+		return "", "", "", err
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+	if comment != "" {
+		authorizedKey += " " + strings.ReplaceAll(comment, " ", "-")
+	}
+	return string(pem.EncodeToMemory(block)), authorizedKey, ssh.FingerprintSHA256(sshPub), nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
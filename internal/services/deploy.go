@@ -1,207 +1,890 @@
 package services
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
+	"sync"
+	"time"
 
+	"deployment-platform/internal/archive"
+	"deployment-platform/internal/config"
 	"deployment-platform/internal/models"
+	"deployment-platform/internal/queue"
+	"deployment-platform/internal/secrets"
+	"deployment-platform/internal/services/builder"
+	"deployment-platform/internal/storage"
 
-	"deployment-platform/internal/services/websocket"
-
-	"github.com/go-git/go-git/v5"
-	"github.com/streadway/amqp"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-type DeployService struct {
-	db        *gorm.DB
-	rmq       *RabbitMQ
-	s3Service *S3Service
-	hub       *websocket.Hub
+const (
+	deploymentsQueue = "deployments"
+	cancelChannel    = "deployments:cancel"
+
+	// userBusyBackoff delays requeueing jobs that cannot run right now, e.g.
+	// because their user is at the concurrency limit
+	userBusyBackoff = 2 * time.Second
+)
+
+// ErrDeploymentCancelled is the context cause used when a user stops a build.
+var ErrDeploymentCancelled = errors.New("deployment cancelled")
+
+// errDeploymentDeleted reports that a deployment was deleted while it ran.
+var errDeploymentDeleted = errors.New("deployment deleted")
+
+// ErrWorkerShutdown is the context cause used when a draining worker gives up
+// on a build so it can be requeued for another worker.
+var ErrWorkerShutdown = errors.New("worker shutting down")
+
+// WorkerOptions controls how many builds a worker runs and for how long.
+type WorkerOptions struct {
+	BuildTimeout  time.Duration
+	Concurrency   int           // builds run in parallel, also used as the prefetch count
+	MaxPerUser    int           // builds of a single user run in parallel, 0 for no limit
+	DrainTimeout  time.Duration // how long in-flight builds may finish after shutdown starts
+	MaxAttempts   int           // attempts for transient failures before a job is dead-lettered
+	GitKnownHosts string        // known_hosts file for SSH clones, go-git's default when empty
+	NodeVersions  []string      // Node.js majors builds can select
+	NodeDefault   string        // Node.js major for projects that don't ask for one
+
+	CacheEnabled bool
+	CacheMaxSize int64         // bytes, larger caches are not saved
+	CacheKeep    int           // cache archives kept per repository
+	CacheTTL     time.Duration // caches older than this are neither restored nor kept
+
+	UploadLimits archive.Limits // extraction limits for uploaded archives
 }
 
-func NewDeployService(db *gorm.DB, rmq *RabbitMQ, s3 *S3Service, hub *websocket.Hub) *DeployService {
-	service := &DeployService{
-		db:        db,
-		rmq:       rmq,
-		s3Service: s3,
-		hub:       hub,
+func NewWorkerOptions(cfg *config.Config) WorkerOptions {
+	return WorkerOptions{
+		BuildTimeout:  cfg.BuildTimeout,
+		Concurrency:   cfg.WorkerConcurrency,
+		MaxPerUser:    cfg.WorkerMaxPerUser,
+		DrainTimeout:  cfg.WorkerDrainTimeout,
+		MaxAttempts:   cfg.JobMaxAttempts,
+		GitKnownHosts: cfg.GitKnownHosts,
+		NodeVersions:  cfg.BuildNodeVersions,
+		NodeDefault:   cfg.BuildNodeDefault,
+		CacheEnabled:  cfg.BuildCacheEnabled,
+		CacheMaxSize:  int64(cfg.BuildCacheMaxSize) << 20,
+		CacheKeep:     cfg.BuildCacheKeep,
+		CacheTTL:      cfg.BuildCacheTTL,
+		UploadLimits: archive.Limits{
+			MaxBytes: int64(cfg.UploadMaxExtracted) << 20,
+			MaxFiles: cfg.UploadMaxFiles,
+		},
 	}
+}
 
-	// Start consuming messages
-	go service.StartWorker()
+type DeployService struct {
+	db      *gorm.DB
+	queue   queue.JobQueue
+	store   storage.ObjectStore
+	redis   *RedisService
+	logs    LogPublisher
+	runner  builder.BuildRunner
+	secrets *secrets.Box
+	opts    WorkerOptions
+
+	// running maps deploy IDs of in-flight builds to their cancel functions
+	running   map[string]context.CancelCauseFunc
+	perUser   map[uint]int
+	runningMu sync.Mutex
+}
+
+// deploymentJob is the message published to the deployments queue.
+type deploymentJob struct {
+	DeployID string `json:"deploy_id"`
+	RepoURL  string `json:"repo_url"`
+	UserID   uint   `json:"user_id"`
+	Attempt  int    `json:"attempt"` // attempts already made
+}
 
-	return service
+func NewDeployService(db *gorm.DB, jobQueue queue.JobQueue, store storage.ObjectStore, redis *RedisService, logs LogPublisher, runner builder.BuildRunner, box *secrets.Box, opts WorkerOptions) *DeployService {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+
+	return &DeployService{
+		db:      db,
+		queue:   jobQueue,
+		store:   store,
+		redis:   redis,
+		logs:    logs,
+		runner:  runner,
+		secrets: box,
+		opts:    opts,
+		running: make(map[string]context.CancelCauseFunc),
+		perUser: make(map[uint]int),
+	}
 }
 
 func (s *DeployService) QueueDeployment(deployment *models.Deployment) error {
-	body, err := json.Marshal(map[string]interface{}{
-		"deploy_id": deployment.DeployID,
-		"repo_url":  deployment.RepoURL,
+	body, err := json.Marshal(deploymentJob{
+		DeployID: deployment.DeployID,
+		RepoURL:  deployment.RepoURL,
+		UserID:   deployment.UserID,
 	})
 	if err != nil {
 		return err
 	}
 
-	return s.rmq.Publish("deployments", body)
+	return s.queue.Publish(context.Background(), deploymentsQueue, body)
+}
+
+// CancelDeployment asks whichever worker is running the build to stop it.
+func (s *DeployService) CancelDeployment(ctx context.Context, deployID string) error {
+	return s.redis.Publish(ctx, cancelChannel, deployID)
+}
+
+// PurgeDeployment stops a deployment's build if one is running and removes
+// its stored source, build output and cached responses.
+func (s *DeployService) PurgeDeployment(ctx context.Context, deployID string) error {
+	if err := s.CancelDeployment(ctx, deployID); err != nil {
+		log.Printf("Failed to signal cancellation for %s: %v", deployID, err)
+	}
+	return s.purgeArtifacts(ctx, deployID)
+}
+
+func (s *DeployService) purgeArtifacts(ctx context.Context, deployID string) error {
+	for _, prefix := range []string{"source/", "dist/"} {
+		if _, err := s.store.DeleteByPrefix(ctx, prefix+deployID+"/"); err != nil {
+			return fmt.Errorf("failed to delete %s%s: %w", prefix, deployID, err)
+		}
+	}
+
+	if _, err := s.redis.DeleteByPattern(ctx, DeploymentCachePattern(deployID)); err != nil {
+		return fmt.Errorf("failed to invalidate cache: %w", err)
+	}
+	return nil
+}
+
+func (s *DeployService) listenForCancellations(ctx context.Context) {
+	sub := s.redis.Subscribe(ctx, cancelChannel)
+	defer sub.Close()
+
+	for msg := range sub.Channel() {
+		s.cancelBuild(msg.Payload, ErrDeploymentCancelled)
+	}
+}
+
+// cancelBuild stops the build of deployID if this worker is running it.
+func (s *DeployService) cancelBuild(deployID string, cause error) {
+	s.runningMu.Lock()
+	cancel, ok := s.running[deployID]
+	s.runningMu.Unlock()
+
+	if ok {
+		log.Printf("Cancelling deployment: %s", deployID)
+		cancel(cause)
+	}
 }
 
-func (s *DeployService) StartWorker() {
-	msgs, err := s.rmq.Consume("deployments")
+// StartWorker consumes deployment jobs with up to Concurrency builds in
+// flight until ctx is cancelled. It then stops consuming, gives running
+// builds DrainTimeout to finish and requeues whatever is left.
+func (s *DeployService) StartWorker(ctx context.Context) {
+	consumeCtx, stopConsuming := context.WithCancel(context.Background())
+	defer stopConsuming()
+
+	jobs, err := s.queue.Consume(consumeCtx, deploymentsQueue, s.opts.Concurrency)
 	if err != nil {
 		log.Fatal("Failed to start worker:", err)
 	}
 
-	log.Println("Worker started, waiting for messages...")
+	// Cancellations must keep arriving while the worker drains
+	listenCtx, stopListening := context.WithCancel(context.Background())
+	defer stopListening()
+	go s.listenForCancellations(listenCtx)
+
+	log.Printf("Worker started with %d slots, waiting for messages...", s.opts.Concurrency)
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, s.opts.Concurrency)
+
+consume:
+	for {
+		select {
+		case <-ctx.Done():
+			break consume
+		case job, ok := <-jobs:
+			if !ok {
+				break consume
+			}
+
+			slots <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				s.processDeployment(job)
+			}()
+		}
+	}
+
+	log.Println("Worker draining...")
+	stopConsuming()
+	// Prefetched jobs that never started go back to the queue
+	for job := range jobs {
+		s.queue.Nack(job, true)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(s.opts.DrainTimeout):
+		log.Println("Drain timeout reached, requeueing in-flight deployments")
+		s.runningMu.Lock()
+		for _, cancel := range s.running {
+			cancel(ErrWorkerShutdown)
+		}
+		s.runningMu.Unlock()
+		<-done
+	}
+
+	log.Println("Worker stopped")
+}
+
+// DeadLetters lists deployment jobs that were rejected or ran out of retries.
+func (s *DeployService) DeadLetters(limit int) ([]queue.DeadLetter, error) {
+	return s.queue.DeadLetters(deploymentsQueue, limit)
+}
+
+// ReplayDeadLetters requeues dead-lettered jobs with a fresh attempt budget.
+// An empty deployID replays every job.
+func (s *DeployService) ReplayDeadLetters(deployID string) (int, error) {
+	return s.queue.ReplayDeadLetters(deploymentsQueue, func(body []byte) ([]byte, bool) {
+		var job deploymentJob
+		if err := json.Unmarshal(body, &job); err != nil || job.DeployID == "" {
+			// Malformed jobs would only be rejected again
+			return nil, false
+		}
+		if deployID != "" && job.DeployID != deployID {
+			return nil, false
+		}
+
+		if err := s.db.Model(&models.Deployment{}).Where("deploy_id = ?", job.DeployID).
+			Updates(map[string]interface{}{"status": "pending", "error_msg": "", "attempts": 0}).Error; err != nil {
+			log.Printf("Failed to reset deployment %s for replay: %v", job.DeployID, err)
+			return nil, false
+		}
+
+		job.Attempt = 0
+		replay, _ := json.Marshal(job)
+		return replay, true
+	})
+}
+
+// ActiveBuilds reports how many deployments this worker is building.
+func (s *DeployService) ActiveBuilds() int {
+	s.runningMu.Lock()
+	defer s.runningMu.Unlock()
+	return len(s.running)
+}
+
+// acquireUserSlot reserves one of the user's concurrent build slots.
+func (s *DeployService) acquireUserSlot(userID uint) bool {
+	s.runningMu.Lock()
+	defer s.runningMu.Unlock()
+
+	if s.opts.MaxPerUser > 0 && s.perUser[userID] >= s.opts.MaxPerUser {
+		return false
+	}
+	s.perUser[userID]++
+	return true
+}
+
+func (s *DeployService) releaseUserSlot(userID uint) {
+	s.runningMu.Lock()
+	defer s.runningMu.Unlock()
 
-	for msg := range msgs {
-		s.processDeployment(msg)
+	s.perUser[userID]--
+	if s.perUser[userID] <= 0 {
+		delete(s.perUser, userID)
 	}
 }
 
-func (s *DeployService) processDeployment(msg amqp.Delivery) {
-	var data map[string]interface{}
-	if err := json.Unmarshal(msg.Body, &data); err != nil {
-		log.Println("Error unmarshaling message:", err)
-		msg.Nack(false, false)
+func (s *DeployService) processDeployment(msg *queue.Job) {
+	var job deploymentJob
+	if err := json.Unmarshal(msg.Body, &job); err != nil || job.DeployID == "" {
+		log.Println("Dead-lettering malformed message:", err)
+		s.queue.Nack(msg, false)
 		return
 	}
 
-	deployID := data["deploy_id"].(string)
-	repoURL := data["repo_url"].(string)
+	deployID := job.DeployID
+
+	// A broker redelivering after a reconnect, or a reclaimed Postgres job,
+	// can hand this worker a deployment it is still building. The running
+	// build settles its own delivery; this one comes back once it is done.
+	ctx, cancel, ok := s.trackBuild(deployID)
+	if !ok {
+		log.Printf("Deployment %s is already being built, requeueing duplicate delivery", deployID)
+		time.Sleep(userBusyBackoff)
+		s.queue.Nack(msg, true)
+		return
+	}
+	defer cancel()
+
+	if !s.acquireUserSlot(job.UserID) {
+		// Give other users' jobs a chance before this one comes back around
+		time.Sleep(userBusyBackoff)
+		s.queue.Nack(msg, true)
+		return
+	}
+	defer s.releaseUserSlot(job.UserID)
 
 	log.Printf("Processing deployment: %s", deployID)
 
 	var deployment models.Deployment
 	if err := s.db.Where("deploy_id = ?", deployID).First(&deployment).Error; err != nil {
 		log.Println("Error finding deployment:", err)
-		msg.Nack(false, false)
+		if isTransientDatabaseError(err) {
+			time.Sleep(userBusyBackoff)
+			s.queue.Nack(msg, true)
+		} else {
+			// Deleted before it was built, nothing left to do
+			s.queue.Ack(msg)
+		}
 		return
 	}
 
-	// Clone repository
-	deployment.Status = "cloning"
-	s.db.Save(&deployment)
+	switch deployment.Status {
+	case "cancelled":
+		log.Printf("Skipping cancelled deployment: %s", deployID)
+		s.queue.Ack(msg)
+		return
+	case "deployed", "skipped", "failed":
+		// A stale copy of a job that another delivery already finished
+		log.Printf("Skipping finished deployment: %s", deployID)
+		s.queue.Ack(msg)
+		return
+	}
 
-	tmpDir := filepath.Join("/tmp", deployID)
-	if err := s.cloneRepo(repoURL, tmpDir); err != nil {
+	// Every run gets its own workspace, so a run never removes another's
+	tmpDir, err := os.MkdirTemp("", "deploy-"+deployID+"-")
+	if err != nil {
+		log.Printf("Failed to create workspace for %s: %v", deployID, err)
+		time.Sleep(userBusyBackoff)
+		s.queue.Nack(msg, true)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	job.Attempt++
+	deployment.Attempts = job.Attempt
+	deployment.ErrorMsg = ""
+
+	redact := &redactor{}
+	err = redact.redactError(s.runDeployment(ctx, &deployment, tmpDir, redact))
+	switch {
+	case err == nil:
+		deployment.Status = "deployed"
+		log.Printf("Deployment completed: %s", deployID)
+	case errors.Is(err, errBuildSkipped):
+		deployment.Status = "skipped"
+		log.Printf("Deployment skipped, nothing changed: %s", deployID)
+	case errors.Is(context.Cause(ctx), ErrDeploymentCancelled):
+		deployment.Status = "cancelled"
+		deployment.ErrorMsg = "Deployment cancelled by user"
+		s.logs.BroadcastLog(deployID, "Deployment cancelled")
+		log.Printf("Deployment cancelled: %s", deployID)
+	case errors.Is(context.Cause(ctx), ErrWorkerShutdown):
+		deployment.Status = "pending"
+		deployment.BuildLog = ""
+		s.saveDeployment(&deployment)
+		s.logs.BroadcastLog(deployID, "Worker shutting down, deployment requeued")
+		s.queue.Nack(msg, true)
+		return
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		deployment.Status = "failed"
+		deployment.ErrorMsg = fmt.Sprintf("Build timed out after %s", s.opts.BuildTimeout)
+		s.logs.BroadcastLog(deployID, deployment.ErrorMsg)
+	case IsRetryable(err) && job.Attempt < s.opts.MaxAttempts:
+		body, _ := json.Marshal(job)
+		delay, pubErr := s.queue.Retry(msg, body, job.Attempt)
+		if pubErr != nil {
+			log.Printf("Failed to schedule retry for %s: %v", deployID, pubErr)
+			s.queue.Nack(msg, true)
+			return
+		}
+		deployment.Status = "retrying"
+		deployment.ErrorMsg = fmt.Sprintf("Attempt %d of %d failed: %v (retrying in %s)", job.Attempt, s.opts.MaxAttempts, err, delay)
+		s.logs.BroadcastLog(deployID, deployment.ErrorMsg)
+	case IsRetryable(err):
+		// Out of attempts: keep the job around for an operator to replay
 		deployment.Status = "failed"
-		deployment.ErrorMsg = fmt.Sprintf("Clone failed: %v", err)
-		s.db.Save(&deployment)
-		msg.Ack(false)
+		deployment.ErrorMsg = fmt.Sprintf("%v (gave up after %d attempts)", err, job.Attempt)
+		s.finishDeployment(&deployment)
+		s.queue.Nack(msg, false)
 		return
+	default:
+		deployment.Status = "failed"
+		deployment.ErrorMsg = err.Error()
 	}
 
-	// Upload files to S3
-	deployment.Status = "uploading"
-	s.db.Save(&deployment)
+	s.finishDeployment(&deployment)
+	s.queue.Ack(msg)
+}
 
-	if err := s.s3Service.UploadDirectory(tmpDir, fmt.Sprintf("source/%s", deployID)); err != nil {
-		deployment.Status = "failed"
-		deployment.ErrorMsg = fmt.Sprintf("Upload failed: %v", err)
-		s.db.Save(&deployment)
-		msg.Ack(false)
-		return
+// saveDeployment persists build progress. It updates the existing row only,
+// where plain Save would fall back to an upsert and resurrect a deployment
+// deleted mid-build, and never overwrites a cancellation recorded by the API:
+// the save is dropped, the build is stopped and ErrDeploymentCancelled is
+// returned. A deleted deployment returns errDeploymentDeleted.
+func (s *DeployService) saveDeployment(deployment *models.Deployment) error {
+	query := s.db.Model(deployment).Select("*")
+	if deployment.Status != "cancelled" {
+		query = query.Where("status <> ?", "cancelled")
+	}
+	result := query.Updates(deployment)
+	if result.Error != nil {
+		log.Printf("Failed to save deployment %s: %v", deployment.DeployID, result.Error)
+		return nil
+	}
+	if result.RowsAffected > 0 {
+		return nil
 	}
 
-	// Build project
-	deployment.Status = "building"
-	s.db.Save(&deployment)
-	s.hub.BroadcastLog(deployID, "Starting build process...")
+	var current models.Deployment
+	if err := s.db.Select("status").First(&current, deployment.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errDeploymentDeleted
+		}
+		log.Printf("Failed to check deployment %s: %v", deployment.DeployID, err)
+		return nil
+	}
+	if current.Status == "cancelled" {
+		s.cancelBuild(deployment.DeployID, ErrDeploymentCancelled)
+		return ErrDeploymentCancelled
+	}
+	return nil
+}
 
-	buildLog, err := s.buildProject(tmpDir, deployID)
-	deployment.BuildLog = buildLog
-	if err != nil {
-		deployment.Status = "failed"
-		deployment.ErrorMsg = fmt.Sprintf("Build failed: %v", err)
-		s.db.Save(&deployment)
-		msg.Ack(false)
+// finishDeployment records the final state of a build. If the deployment was
+// deleted while it ran, whatever the build uploaded after the API purged it
+// is removed again.
+func (s *DeployService) finishDeployment(deployment *models.Deployment) {
+	err := s.saveDeployment(deployment)
+	if errors.Is(err, ErrDeploymentCancelled) {
+		// Cancelled after the build's last progress update
+		deployment.Status = "cancelled"
+		deployment.ErrorMsg = "Deployment cancelled by user"
+		err = s.saveDeployment(deployment)
+	}
+	if err == nil {
+		if deployment.Status == "deployed" && deployment.Target == models.TargetProduction {
+			s.promoteDeployment(deployment)
+		}
 		return
 	}
 
-	// Upload dist files
-	distDir := filepath.Join(tmpDir, "dist")
-	if err := s.s3Service.UploadDirectory(distDir, fmt.Sprintf("dist/%s", deployID)); err != nil {
-		deployment.Status = "failed"
-		deployment.ErrorMsg = fmt.Sprintf("Dist upload failed: %v", err)
-		s.db.Save(&deployment)
-		msg.Ack(false)
+	log.Printf("Deployment %s was deleted during its build, purging artifacts", deployment.DeployID)
+	if err := s.purgeArtifacts(context.Background(), deployment.DeployID); err != nil {
+		log.Printf("Failed to purge artifacts for %s: %v", deployment.DeployID, err)
+	}
+}
+
+// promoteDeployment points the project's production alias at a deployment
+// unless a newer production deployment is already live.
+func (s *DeployService) promoteDeployment(deployment *models.Deployment) {
+	if deployment.ProjectID == nil {
 		return
 	}
 
-	// Clean up
-	os.RemoveAll(tmpDir)
+	var alias models.Alias
+	promoted := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("project_id = ?", *deployment.ProjectID).First(&alias).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil // project deleted meanwhile
+			}
+			return err
+		}
 
-	// Mark as deployed
-	deployment.Status = "deployed"
-	s.db.Save(&deployment)
+		var newer int64
+		err = tx.Model(&models.Deployment{}).
+			Where("project_id = ? AND target = ? AND status = ? AND created_at > ?",
+				*deployment.ProjectID, models.TargetProduction, "deployed", deployment.CreatedAt).
+			Count(&newer).Error
+		if err != nil || newer > 0 {
+			return err
+		}
 
-	msg.Ack(false)
-	log.Printf("Deployment completed: %s", deployID)
+		promoted = true
+		return PointAlias(tx, &alias, deployment.DeployID)
+	})
+	if err != nil {
+		log.Printf("Failed to promote deployment %s: %v", deployment.DeployID, err)
+		return
+	}
+	if !promoted {
+		log.Printf("Not promoting deployment %s: project deleted or a newer production deployment is live", deployment.DeployID)
+		return
+	}
+
+	s.redis.InvalidateAlias(context.Background(), alias.Hostname)
+	log.Printf("Promoted deployment %s to %s", deployment.DeployID, alias.Hostname)
 }
 
-func (s *DeployService) cloneRepo(repoURL, destPath string) error {
-	_, err := git.PlainClone(destPath, false, &git.CloneOptions{
-		URL:      repoURL,
-		Progress: os.Stdout,
-	})
-	return err
+// trackBuild returns a context bounded by the build timeout that can also be
+// cancelled through CancelDeployment from any API instance. It reports false
+// when this worker is already building the deployment.
+func (s *DeployService) trackBuild(deployID string) (context.Context, context.CancelFunc, bool) {
+	s.runningMu.Lock()
+	if _, ok := s.running[deployID]; ok {
+		s.runningMu.Unlock()
+		return nil, nil, false
+	}
+	parent, cancelCause := context.WithCancelCause(context.Background())
+	s.running[deployID] = cancelCause
+	s.runningMu.Unlock()
+
+	ctx, cancelTimeout := context.WithTimeout(parent, s.opts.BuildTimeout)
+	return ctx, func() {
+		s.runningMu.Lock()
+		delete(s.running, deployID)
+		s.runningMu.Unlock()
+
+		cancelTimeout()
+		cancelCause(nil)
+	}, true
 }
 
-func (s *DeployService) buildProject(projectPath, deployID string) (string, error) {
-	// Install dependencies
-	s.hub.BroadcastLog(deployID, "Installing dependencies...")
-	installCmd := exec.Command("npm", "install")
-	installCmd.Dir = projectPath
+// runDeployment clones, builds and publishes a deployment. Returned errors
+// are user-facing and end up in Deployment.ErrorMsg.
+func (s *DeployService) runDeployment(ctx context.Context, deployment *models.Deployment, tmpDir string, redact *redactor) error {
+	deployID := deployment.DeployID
 
-	installOutput, err := s.runCommandWithStreaming(installCmd, deployID)
+	if deployment.Source == models.SourceUpload {
+		// Uploaded archives are already in object storage
+		deployment.Status = "extracting"
+		s.saveDeployment(deployment)
+
+		if err := s.extractUpload(ctx, deployID, tmpDir); err != nil {
+			return err
+		}
+	} else {
+		// Clone repository
+		deployment.Status = "cloning"
+		s.saveDeployment(deployment)
+
+		if err := s.cloneRepo(ctx, deployment, tmpDir, redact); err != nil {
+			return classify(fmt.Errorf("Clone failed: %w", err), isTransientCloneError)
+		}
+
+		// Upload files to object storage
+		deployment.Status = "uploading"
+		s.saveDeployment(deployment)
+
+		if err := storage.UploadDirectory(ctx, s.store, tmpDir, fmt.Sprintf("source/%s", deployID)); err != nil {
+			return classify(fmt.Errorf("Upload failed: %w", err), isTransientStorageError)
+		}
+	}
+
+	// Load project configuration, repository settings win over the project's
+	project, err := s.loadProject(deployment)
 	if err != nil {
-		return installOutput, err
+		return classify(fmt.Errorf("Failed to load project: %w", err), isTransientDatabaseError)
+	}
+	repoConfig, err := builder.LoadConfig(tmpDir)
+	if err != nil {
+		return err
+	}
+
+	var projectConfig *models.ProjectConfig
+	if project != nil {
+		projectConfig = builder.MergeConfig(project.BuildSettings, repoConfig)
+	} else {
+		projectConfig = repoConfig
+	}
+
+	// The deployment's own root directory wins over the configured one
+	rootDir := "."
+	if deployment.RootDirectory != "" {
+		rootDir = path.Clean(deployment.RootDirectory)
+	} else if projectConfig != nil && projectConfig.RootDirectory != "" {
+		rootDir = path.Clean(projectConfig.RootDirectory)
+	}
+	projectDir := filepath.Join(tmpDir, rootDir)
+	if rootDir != "." {
+		if !insideDir(tmpDir, projectDir) {
+			return fmt.Errorf("Root directory %q not found", rootDir)
+		}
+	}
+	deployment.RootDirectory = rootDir
+
+	// Detect framework, unless the upload is already the site
+	var plan *builder.Plan
+	if deployment.Prebuilt {
+		plan = builder.PrebuiltPlan(projectConfig)
+	} else if plan, err = builder.ResolvePlan(projectDir, tmpDir, projectConfig); err != nil {
+		return fmt.Errorf("Detection failed: %v", err)
+	}
+	deployment.Framework = plan.Framework
+	deployment.Config = resolvedConfig(projectConfig, plan)
+
+	// Ignored build step: nothing the build depends on changed since the
+	// last deployment, which keeps serving
+	if previous := s.unchangedDeployment(ctx, deployment, tmpDir, plan, redact); previous != nil {
+		deployment.UnchangedFrom = previous.DeployID
+		deployment.DeployedURL = previous.DeployedURL
+		s.logs.BroadcastLog(deployID, fmt.Sprintf("No changes in %s since %.7s (deployment %s), skipping build",
+			rootDir, previous.CommitSHA, previous.DeployID))
+		return errBuildSkipped
 	}
 
 	// Build project
-	s.hub.BroadcastLog(deployID, "Building project...")
-	buildCmd := exec.Command("npm", "run", "build")
-	buildCmd.Dir = projectPath
+	deployment.Status = "building"
+	s.saveDeployment(deployment)
+	s.logs.BroadcastLog(deployID, fmt.Sprintf("Detected framework: %s", plan.Framework))
+	if pm := plan.PackageManager; pm != nil {
+		if pm.Lockfile != "" {
+			s.logs.BroadcastLog(deployID, fmt.Sprintf("Detected package manager: %s (%s)", pm.Name, pm.Lockfile))
+		} else {
+			s.logs.BroadcastLog(deployID, fmt.Sprintf("Detected package manager: %s (no lockfile)", pm.Name))
+		}
+	}
+	s.logs.BroadcastLog(deployID, "Starting build process...")
 
-	buildOutput, err := s.runCommandWithStreaming(buildCmd, deployID)
+	env, err := s.buildEnv(deployment, redact)
+	if err != nil {
+		return err
+	}
 
-	fullLog := installOutput + "\n" + buildOutput
-	return fullLog, err
-}
+	if plan.Install != nil || plan.Build != nil {
+		if err := s.selectNode(deployment, projectConfig, projectDir, tmpDir); err != nil {
+			return err
+		}
+	}
+
+	// Restore dependencies from an earlier build unless asked not to
+	var cache *buildCache
+	cacheHit := false
+	if plan.Install != nil {
+		cache = s.newBuildCache(deployment, tmpDir, rootDir, path.Join(rootDir, plan.InstallDir))
+	}
+	if cache != nil && !deployment.NoCache {
+		cacheHit = s.restoreCache(ctx, cache, tmpDir, deployID)
+	}
+
+	buildLog, err := s.buildProject(ctx, tmpDir, rootDir, deployID, plan, deployment.NodeVersion, env, redact)
+	deployment.BuildLog = buildLog
+	if pm := plan.PackageManager; pm != nil {
+		deployment.PackageManager = pm.Name
+		deployment.PackageManagerVersion = builder.UsedVersion(buildLog, pm.Name)
+		if deployment.PackageManagerVersion == "" {
+			deployment.PackageManagerVersion = pm.Version
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Build failed: %v", err)
+	}
 
-func (s *DeployService) runCommandWithStreaming(cmd *exec.Cmd, deployID string) (string, error) {
-	stdout, err := cmd.StdoutPipe()
+	// Upload build output. The build may have replaced anything in the
+	// workspace since it was checked, so the output directory is opened
+	// through a root on the workspace: a symlink to the host fails here.
+	dist, err := openOutputDir(tmpDir, filepath.Join(rootDir, plan.OutputDir))
 	if err != nil {
-		return "", err
+		return fmt.Errorf("Build output directory %q not found", plan.OutputDir)
 	}
-	defer stdout.Close()
+	defer dist.Close()
 
-	if err := cmd.Start(); err != nil {
-		return "", err
+	if err := storage.UploadRoot(ctx, s.store, dist, fmt.Sprintf("dist/%s", deployID)); err != nil {
+		return classify(fmt.Errorf("Dist upload failed: %w", err), isTransientStorageError)
 	}
 
-	var output []byte
-	buf := make([]byte, 1024)
-	for {
-		n, err := stdout.Read(buf)
-		if n > 0 {
-			chunk := buf[:n]
-			output = append(output, chunk...)
-			s.hub.BroadcastLog(deployID, string(chunk))
+	if cache != nil && !cacheHit {
+		s.saveCache(ctx, cache, tmpDir, deployID)
+	}
+
+	return nil
+}
+
+// resolvedConfig records the commands that were actually used alongside the
+// routing rules from gopher.json, so the deployment can be inspected and served later.
+func resolvedConfig(cfg *models.ProjectConfig, plan *builder.Plan) *models.ProjectConfig {
+	resolved := models.ProjectConfig{}
+	if cfg != nil {
+		resolved = *cfg
+	}
+
+	if resolved.InstallCommand == nil {
+		install := builder.FormatCommand(plan.Install)
+		resolved.InstallCommand = &install
+	}
+	if resolved.BuildCommand == nil {
+		build := builder.FormatCommand(plan.Build)
+		resolved.BuildCommand = &build
+	}
+	resolved.OutputDirectory = plan.OutputDir
+
+	return &resolved
+}
+
+// loadProject returns the project a deployment belongs to, or nil for
+// standalone deployments and projects deleted since the deployment was queued.
+func (s *DeployService) loadProject(deployment *models.Deployment) (*models.Project, error) {
+	if deployment.ProjectID == nil {
+		return nil, nil
+	}
+
+	var project models.Project
+	if err := s.db.First(&project, *deployment.ProjectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &project, nil
+}
+
+// ResolveNodeVersion resolves a requested Node.js version to one of the
+// majors workers provide.
+func (s *DeployService) ResolveNodeVersion(spec string) (string, error) {
+	return builder.ResolveNodeVersion(spec, s.opts.NodeVersions, s.opts.NodeDefault)
+}
+
+// selectNode resolves the Node.js major to build with and records it on the
+// deployment. A version given with the deployment request wins over the
+// project's settings and gopher.json, which win over .nvmrc, .node-version
+// and engines.node.
+func (s *DeployService) selectNode(deployment *models.Deployment, cfg *models.ProjectConfig, projectDir, repoDir string) error {
+	spec, source := deployment.NodeVersion, "deployment request"
+	if spec == "" && cfg != nil && cfg.NodeVersion != "" {
+		spec, source = cfg.NodeVersion, "nodeVersion setting"
+	}
+	if spec == "" {
+		spec, source = builder.NodeVersionSpec(projectDir, repoDir)
+	}
+
+	version, err := s.ResolveNodeVersion(spec)
+	if err != nil {
+		return fmt.Errorf("%v (from %s)", err, source)
+	}
+	deployment.NodeVersion = version
+	if source == "" {
+		source = "default"
+	}
+	s.logs.BroadcastLog(deployment.DeployID, fmt.Sprintf("Using Node.js %s (%s)", version, source))
+	return nil
+}
+
+func (s *DeployService) buildProject(ctx context.Context, workspace, rootDir, deployID string, plan *builder.Plan, node string, env []string, redact *redactor) (string, error) {
+	var fullLog string
+
+	// Install dependencies
+	if plan.Install != nil {
+		installDir := path.Join(rootDir, plan.InstallDir)
+		if installDir != rootDir {
+			s.logs.BroadcastLog(deployID, fmt.Sprintf("Installing workspace dependencies from %s...", installDir))
+		} else {
+			s.logs.BroadcastLog(deployID, "Installing dependencies...")
 		}
+		installOutput, err := s.runCommandWithStreaming(ctx, builder.RunSpec{
+			ID:        deployID + "-install",
+			Workspace: workspace,
+			Dir:       installDir,
+			Command:   plan.Install,
+			Env:       env,
+			CacheDir:  s.cacheDir(),
+			Node:      node,
+			Toolchain: plan.Toolchain,
+		}, deployID, redact)
+		fullLog += installOutput
 		if err != nil {
-			break
+			return fullLog, err
 		}
 	}
 
-	if err := cmd.Wait(); err != nil {
-		return string(output), err
+	// Build project
+	if plan.Build == nil {
+		s.logs.BroadcastLog(deployID, "No build step, serving files as-is")
+		return fullLog, nil
 	}
 
-	return string(output), nil
+	s.logs.BroadcastLog(deployID, "Building project...")
+	buildOutput, err := s.runCommandWithStreaming(ctx, builder.RunSpec{
+		ID:        deployID + "-build",
+		Workspace: workspace,
+		Dir:       rootDir,
+		Command:   plan.Build,
+		Env:       env,
+		CacheDir:  s.cacheDir(),
+		Node:      node,
+		Toolchain: plan.Toolchain,
+	}, deployID, redact)
+
+	fullLog += "\n" + buildOutput
+	return fullLog, err
+}
+
+func (s *DeployService) runCommandWithStreaming(ctx context.Context, spec builder.RunSpec, deployID string, redact *redactor) (string, error) {
+	stream := &logStream{logs: s.logs, deployID: deployID, redact: redact}
+	err := s.runner.Run(ctx, spec, stream)
+	stream.Flush()
+	return stream.String(), err
+}
+
+// logStream collects build output while forwarding each chunk to log
+// subscribers, with the build's secrets masked. Output arrives in arbitrary
+// pieces, so the end of each write that could be the start of a secret is
+// held back until the next one or Flush.
+type logStream struct {
+	logs     LogPublisher
+	deployID string
+	redact   *redactor
+	mu       sync.Mutex
+	pending  string
+	buf      bytes.Buffer
+}
+
+func (l *logStream) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.pending += string(p)
+	cut := l.redact.safeCut(l.pending)
+	l.emit(l.pending[:cut])
+	l.pending = l.pending[cut:]
+	return len(p), nil
+}
+
+// Flush releases the output held back at the end of the stream.
+func (l *logStream) Flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.emit(l.pending)
+	l.pending = ""
+}
+
+func (l *logStream) emit(s string) {
+	if s == "" {
+		return
+	}
+	chunk := l.redact.redact(s)
+	l.buf.WriteString(chunk)
+	l.logs.BroadcastLog(l.deployID, chunk)
+}
+
+func (l *logStream) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.String()
 }
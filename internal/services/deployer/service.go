@@ -2,20 +2,76 @@ package deployer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
 
 	"deployment-platform/internal/models"
 	"deployment-platform/internal/services"
+	"deployment-platform/internal/storage"
 	"deployment-platform/internal/utils"
 
 	"gorm.io/gorm"
 )
 
+var (
+	ErrProjectNotFound    = errors.New("project not found")
+	ErrRepoMismatch       = errors.New("repo_url does not match the project's repository")
+	ErrInvalidRef         = errors.New("ref must be a branch, tag or commit SHA")
+	ErrCredentialNotFound = errors.New("git credential not found")
+	ErrDeploymentNotFound = errors.New("deployment not found")
+	ErrInvalidNodeVersion = errors.New("invalid node_version")
+	ErrInvalidRootDir     = errors.New("root_directory must be a relative path inside the repository")
+	ErrInvalidTarget      = errors.New("target must be production or preview")
+	ErrUploadGone         = errors.New("the deployment's uploaded archive is no longer available")
+	ErrUnsupportedArchive = services.ErrUnsupportedArchive
+)
+
+var refPattern = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+
+// CreateDeploymentInput describes a deployment request. Ref selects a
+// branch, tag or commit to build; Branch and CommitSHA pin an exact commit
+// of a branch, as push webhooks do. Deployments of a project build its
+// repository and go to production, unless they build something other than
+// the project's default branch. CredentialID selects the git credential
+// to clone with, defaulting to the project's. RootDirectory and NodeVersion
+// override the project's settings and what the repository asks for.
+type CreateDeploymentInput struct {
+	RepoURL       string
+	ProjectID     *uint
+	CredentialID  *uint
+	Ref           string
+	Branch        string
+	CommitSHA     string
+	RootDirectory string
+	NodeVersion   string
+	NoCache       bool
+}
+
+// UploadDeploymentInput describes a deployment of an uploaded archive of
+// Size bytes. Prebuilt archives are published without a build. Uploads to a
+// project go to production unless Target is preview.
+type UploadDeploymentInput struct {
+	ProjectID     *uint
+	Target        string
+	Prebuilt      bool
+	RootDirectory string
+	NodeVersion   string
+	Archive       io.Reader
+	Size          int64
+}
+
 type Service interface {
-	CreateDeployment(ctx context.Context, userID uint, repoURL string) (*models.Deployment, error)
-	GetDeploymentStatus(ctx context.Context, deployID string) (*models.Deployment, error)
+	CreateDeployment(ctx context.Context, userID uint, input CreateDeploymentInput) (*models.Deployment, error)
+	CreateUploadDeployment(ctx context.Context, userID uint, input UploadDeploymentInput) (*models.Deployment, error)
+	Redeploy(ctx context.Context, deployID string, userID uint, noCache bool) (*models.Deployment, error)
+	GetDeploymentStatus(ctx context.Context, deployID string, userID uint) (*models.Deployment, error)
 	GetUserDeployments(ctx context.Context, userID uint) ([]models.Deployment, error)
 	DeleteDeployment(ctx context.Context, deployID string, userID uint) error
+	CancelDeployment(ctx context.Context, deployID string, userID uint) error
 }
 
 type service struct {
@@ -32,35 +88,224 @@ func NewService(db *gorm.DB, deployService *services.DeployService, baseDomain s
 	}
 }
 
-func (s *service) CreateDeployment(ctx context.Context, userID uint, repoURL string) (*models.Deployment, error) {
+func (s *service) CreateDeployment(ctx context.Context, userID uint, input CreateDeploymentInput) (*models.Deployment, error) {
+	if input.Ref != "" && !validRef(input.Ref) {
+		return nil, ErrInvalidRef
+	}
+	if input.RootDirectory != "" && !validRootDir(input.RootDirectory) {
+		return nil, ErrInvalidRootDir
+	}
+	if input.NodeVersion != "" {
+		if _, err := s.deployService.ResolveNodeVersion(input.NodeVersion); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidNodeVersion, err)
+		}
+	}
+
 	deployID := utils.GenerateID(8)
 
 	deployment := &models.Deployment{
-		UserID:      userID,
-		DeployID:    deployID,
-		RepoURL:     repoURL,
-		Status:      "pending",
-		DeployedURL: fmt.Sprintf("http://%s.%s", deployID, s.baseDomain),
+		UserID:        userID,
+		DeployID:      deployID,
+		Source:        models.SourceGit,
+		RepoURL:       input.RepoURL,
+		Ref:           input.Ref,
+		CredentialID:  input.CredentialID,
+		Branch:        input.Branch,
+		CommitSHA:     input.CommitSHA,
+		RootDirectory: input.RootDirectory,
+		NodeVersion:   input.NodeVersion,
+		NoCache:       input.NoCache,
+		Status:        "pending",
+		DeployedURL:   fmt.Sprintf("http://%s.%s", deployID, s.baseDomain),
 	}
 
-	if err := s.db.Create(deployment).Error; err != nil {
+	if input.ProjectID != nil {
+		var project models.Project
+		if err := s.db.Where("id = ? AND user_id = ?", *input.ProjectID, userID).First(&project).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrProjectNotFound
+			}
+			return nil, err
+		}
+		if input.RepoURL != "" && input.RepoURL != project.RepoURL {
+			return nil, ErrRepoMismatch
+		}
+
+		deployment.ProjectID = &project.ID
+		deployment.RepoURL = project.RepoURL
+		if deployment.CredentialID == nil {
+			deployment.CredentialID = project.CredentialID
+		}
+		if input.Branch == "" && input.Ref == "" {
+			// Production deploys the project's branch, not whatever the remote HEAD is
+			deployment.Branch = project.DefaultBranch
+		}
+		deployment.Target = models.TargetProduction
+		if (input.Branch != "" && input.Branch != project.DefaultBranch) ||
+			(input.Ref != "" && input.Ref != project.DefaultBranch) {
+			deployment.Target = models.TargetPreview
+		}
+	}
+
+	if input.CredentialID != nil {
+		var count int64
+		err := s.db.Model(&models.GitCredential{}).Where("id = ? AND user_id = ?", *input.CredentialID, userID).Count(&count).Error
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ErrCredentialNotFound
+		}
+	}
+
+	if err := s.enqueue(deployment); err != nil {
+		return nil, err
+	}
+	return deployment, nil
+}
+
+// CreateUploadDeployment stores an uploaded archive and queues its
+// deployment. The archive's format is checked while it streams to storage.
+func (s *service) CreateUploadDeployment(ctx context.Context, userID uint, input UploadDeploymentInput) (*models.Deployment, error) {
+	if input.RootDirectory != "" && !validRootDir(input.RootDirectory) {
+		return nil, ErrInvalidRootDir
+	}
+	if input.NodeVersion != "" {
+		if _, err := s.deployService.ResolveNodeVersion(input.NodeVersion); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidNodeVersion, err)
+		}
+	}
+
+	deployID := utils.GenerateID(8)
+	deployment := &models.Deployment{
+		UserID:        userID,
+		DeployID:      deployID,
+		Source:        models.SourceUpload,
+		Prebuilt:      input.Prebuilt,
+		RootDirectory: input.RootDirectory,
+		NodeVersion:   input.NodeVersion,
+		Status:        "pending",
+		DeployedURL:   fmt.Sprintf("http://%s.%s", deployID, s.baseDomain),
+	}
+
+	if input.ProjectID != nil {
+		var project models.Project
+		if err := s.db.Where("id = ? AND user_id = ?", *input.ProjectID, userID).First(&project).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrProjectNotFound
+			}
+			return nil, err
+		}
+
+		// The project's repository keys its env vars and build cache
+		deployment.ProjectID = &project.ID
+		deployment.RepoURL = project.RepoURL
+		switch input.Target {
+		case "", models.TargetProduction:
+			deployment.Target = models.TargetProduction
+		case models.TargetPreview:
+			deployment.Target = models.TargetPreview
+		default:
+			return nil, ErrInvalidTarget
+		}
+	} else if input.Target != "" {
+		return nil, ErrInvalidTarget
+	}
+
+	if err := s.deployService.StoreUpload(ctx, deployID, input.Archive, input.Size); err != nil {
+		return nil, err
+	}
+	if err := s.enqueue(deployment); err != nil {
+		if deployment.ID == 0 {
+			s.deployService.DiscardUpload(context.Background(), deployID)
+		}
+		return nil, err
+	}
+	return deployment, nil
+}
+
+// Redeploy builds a deployment's source again as a new deployment of the
+// same project and target, from the same commit when one was recorded.
+func (s *service) Redeploy(ctx context.Context, deployID string, userID uint, noCache bool) (*models.Deployment, error) {
+	var source models.Deployment
+	if err := s.db.Where("deploy_id = ? AND user_id = ?", deployID, userID).First(&source).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeploymentNotFound
+		}
+		return nil, err
+	}
+
+	newID := utils.GenerateID(8)
+	deployment := &models.Deployment{
+		UserID:        userID,
+		DeployID:      newID,
+		ProjectID:     source.ProjectID,
+		Target:        source.Target,
+		Source:        source.Source,
+		Prebuilt:      source.Prebuilt,
+		RepoURL:       source.RepoURL,
+		Ref:           source.Ref,
+		Branch:        source.Branch,
+		CommitSHA:     source.CommitSHA,
+		CredentialID:  source.CredentialID,
+		RootDirectory: source.RootDirectory,
+		NodeVersion:   source.NodeVersion,
+		NoCache:       noCache,
+		Status:        "pending",
+		DeployedURL:   fmt.Sprintf("http://%s.%s", newID, s.baseDomain),
+	}
+	if source.Source == models.SourceUpload {
+		if err := s.deployService.CopyUpload(ctx, source.DeployID, newID); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return nil, ErrUploadGone
+			}
+			return nil, err
+		}
+	}
+	if err := s.enqueue(deployment); err != nil {
 		return nil, err
 	}
+	return deployment, nil
+}
+
+// enqueue stores a new deployment and queues it for a worker.
+func (s *service) enqueue(deployment *models.Deployment) error {
+	if err := s.db.Create(deployment).Error; err != nil {
+		return err
+	}
 
 	// Send to RabbitMQ for processing
 	if err := s.deployService.QueueDeployment(deployment); err != nil {
 		deployment.Status = "failed"
 		deployment.ErrorMsg = err.Error()
 		s.db.Save(deployment)
-		return nil, err
+		return err
 	}
+	return nil
+}
 
-	return deployment, nil
+// validRef rejects refs that could never name a branch, tag or commit,
+// including ones that would be parsed as options or refspecs.
+func validRef(ref string) bool {
+	return len(ref) <= 255 &&
+		refPattern.MatchString(ref) &&
+		!strings.HasPrefix(ref, "-") &&
+		!strings.HasPrefix(ref, "/") &&
+		!strings.Contains(ref, "..")
 }
 
-func (s *service) GetDeploymentStatus(ctx context.Context, deployID string) (*models.Deployment, error) {
+// validRootDir accepts relative paths that stay inside the repository.
+func validRootDir(dir string) bool {
+	if len(dir) > 255 || strings.HasPrefix(dir, "/") || strings.Contains(dir, "\\") {
+		return false
+	}
+	clean := path.Clean(dir)
+	return clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+func (s *service) GetDeploymentStatus(ctx context.Context, deployID string, userID uint) (*models.Deployment, error) {
 	var deployment models.Deployment
-	if err := s.db.Where("deploy_id = ?", deployID).First(&deployment).Error; err != nil {
+	if err := s.db.Where("deploy_id = ? AND user_id = ?", deployID, userID).First(&deployment).Error; err != nil {
 		return nil, err
 	}
 	return &deployment, nil
@@ -82,5 +327,41 @@ func (s *service) DeleteDeployment(ctx context.Context, deployID string, userID
 	if result.RowsAffected == 0 {
 		return fmt.Errorf("deployment not found or unauthorized")
 	}
+
+	// The row is gone first so the worker and request handler stop treating
+	// the deployment as live, then its build, objects and cache are torn down
+	if err := s.deployService.PurgeDeployment(ctx, deployID); err != nil {
+		return fmt.Errorf("deployment deleted but cleanup failed: %w", err)
+	}
 	return nil
 }
+
+var ErrDeploymentFinished = errors.New("deployment has already finished")
+
+func (s *service) CancelDeployment(ctx context.Context, deployID string, userID uint) error {
+	var deployment models.Deployment
+	if err := s.db.Where("deploy_id = ? AND user_id = ?", deployID, userID).First(&deployment).Error; err != nil {
+		return fmt.Errorf("deployment not found or unauthorized")
+	}
+
+	switch deployment.Status {
+	case "deployed", "skipped", "failed", "cancelled":
+		return ErrDeploymentFinished
+	}
+
+	// Jobs still waiting in the queue are skipped by the worker once marked,
+	// and a worker never overwrites the mark, so a build that misses the
+	// signal below stops at its next progress update
+	result := s.db.Model(&models.Deployment{}).
+		Where("id = ? AND status NOT IN ?", deployment.ID, []string{"deployed", "skipped", "failed", "cancelled"}).
+		Updates(map[string]interface{}{"status": "cancelled", "error_msg": "Deployment cancelled by user"})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeploymentFinished
+	}
+
+	// Builds already in flight are stopped by the worker that owns them
+	return s.deployService.CancelDeployment(ctx, deployID)
+}
@@ -0,0 +1,279 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/routing"
+	"deployment-platform/internal/services"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// ChallengePrefix is prepended to a domain to find its verification record.
+const ChallengePrefix = "_gopher-challenge."
+
+var (
+	ErrDomainNotFound     = errors.New("domain not found")
+	ErrDomainExists       = errors.New("domain has already been added")
+	ErrDomainClaimed      = errors.New("domain is already verified by another account")
+	ErrTargetNotFound     = errors.New("deployment or project not found")
+	ErrVerificationFailed = errors.New("verification record not found")
+)
+
+var labelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// TXTResolver looks up DNS TXT records. *net.Resolver satisfies it; tests
+// can stub it.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// ValidationError is returned for input the client has to fix.
+type ValidationError struct {
+	msg string
+}
+
+func (e *ValidationError) Error() string { return e.msg }
+
+// Target is what a domain serves: exactly one of a deployment or a project.
+type Target struct {
+	DeployID  string
+	ProjectID *uint
+}
+
+// VerificationRecord is the DNS record proving ownership of a domain.
+type VerificationRecord struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Service interface {
+	AddDomain(ctx context.Context, userID uint, hostname string, target Target) (*models.Domain, error)
+	GetDomain(ctx context.Context, userID uint, hostname string) (*models.Domain, error)
+	ListDomains(ctx context.Context, userID uint) ([]models.Domain, error)
+	UpdateDomain(ctx context.Context, userID uint, hostname string, target Target) (*models.Domain, error)
+	VerifyDomain(ctx context.Context, userID uint, hostname string) (*models.Domain, error)
+	RemoveDomain(ctx context.Context, userID uint, hostname string) error
+	Verification(domain *models.Domain) VerificationRecord
+}
+
+type service struct {
+	db       *gorm.DB
+	redis    *services.RedisService
+	resolver TXTResolver
+	baseHost string
+}
+
+func NewService(db *gorm.DB, redis *services.RedisService, resolver TXTResolver, baseDomain string) Service {
+	return &service{
+		db:       db,
+		redis:    redis,
+		resolver: resolver,
+		baseHost: routing.Hostname(baseDomain),
+	}
+}
+
+func (s *service) AddDomain(ctx context.Context, userID uint, hostname string, target Target) (*models.Domain, error) {
+	hostname = routing.Hostname(hostname)
+	if err := s.validateHostname(hostname); err != nil {
+		return nil, err
+	}
+	if err := s.checkTarget(userID, target); err != nil {
+		return nil, err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	domain := &models.Domain{
+		UserID:    userID,
+		Hostname:  hostname,
+		DeployID:  target.DeployID,
+		ProjectID: target.ProjectID,
+		Token:     token,
+	}
+	if err := s.db.Create(domain).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrDomainExists
+		}
+		return nil, err
+	}
+	return domain, nil
+}
+
+func (s *service) GetDomain(ctx context.Context, userID uint, hostname string) (*models.Domain, error) {
+	var domain models.Domain
+	err := s.db.Where("user_id = ? AND hostname = ?", userID, routing.Hostname(hostname)).First(&domain).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDomainNotFound
+		}
+		return nil, err
+	}
+	return &domain, nil
+}
+
+func (s *service) ListDomains(ctx context.Context, userID uint) ([]models.Domain, error) {
+	var domains []models.Domain
+	if err := s.db.Where("user_id = ?", userID).Order("hostname").Find(&domains).Error; err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+func (s *service) UpdateDomain(ctx context.Context, userID uint, hostname string, target Target) (*models.Domain, error) {
+	domain, err := s.GetDomain(ctx, userID, hostname)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkTarget(userID, target); err != nil {
+		return nil, err
+	}
+
+	domain.DeployID = target.DeployID
+	domain.ProjectID = target.ProjectID
+	if err := s.db.Select("deploy_id", "project_id").Save(domain).Error; err != nil {
+		return nil, err
+	}
+
+	s.redis.InvalidateDomain(ctx, domain.Hostname)
+	return domain, nil
+}
+
+// VerifyDomain checks the domain's TXT challenge record. Once verified the
+// request handler starts routing the domain.
+func (s *service) VerifyDomain(ctx context.Context, userID uint, hostname string) (*models.Domain, error) {
+	domain, err := s.GetDomain(ctx, userID, hostname)
+	if err != nil {
+		return nil, err
+	}
+	if domain.VerifiedAt != nil {
+		return domain, nil
+	}
+
+	if err := s.checkRecord(ctx, s.Verification(domain)); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	domain.VerifiedAt = &now
+	if err := s.db.Select("verified_at").Save(domain).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrDomainClaimed
+		}
+		return nil, err
+	}
+
+	s.redis.InvalidateDomain(ctx, domain.Hostname)
+	return domain, nil
+}
+
+// checkRecord looks up record and fails with ErrVerificationFailed unless
+// one of its TXT values matches. A missing name counts as a mismatch;
+// temporary DNS failures are returned as they are.
+func (s *service) checkRecord(ctx context.Context, record VerificationRecord) error {
+	values, err := s.resolver.LookupTXT(ctx, record.Name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || dnsErr.IsTemporary {
+			return fmt.Errorf("DNS lookup for %s failed: %w", record.Name, err)
+		}
+		values = nil
+	}
+
+	for _, value := range values {
+		if strings.TrimSpace(value) == record.Value {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: add a TXT record %s with value %q", ErrVerificationFailed, record.Name, record.Value)
+}
+
+func (s *service) RemoveDomain(ctx context.Context, userID uint, hostname string) error {
+	domain, err := s.GetDomain(ctx, userID, hostname)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Delete(domain).Error; err != nil {
+		return err
+	}
+
+	s.redis.InvalidateDomain(ctx, domain.Hostname)
+	return nil
+}
+
+func (s *service) Verification(domain *models.Domain) VerificationRecord {
+	return VerificationRecord{
+		Type:  "TXT",
+		Name:  ChallengePrefix + domain.Hostname,
+		Value: "gopher-verification=" + domain.Token,
+	}
+}
+
+func (s *service) validateHostname(hostname string) error {
+	if len(hostname) > 253 || net.ParseIP(hostname) != nil {
+		return &ValidationError{msg: "hostname must be a domain name"}
+	}
+	if hostname == s.baseHost || strings.HasSuffix(hostname, "."+s.baseHost) {
+		return &ValidationError{msg: "subdomains of the platform domain are managed through aliases"}
+	}
+
+	labels := strings.Split(hostname, ".")
+	if len(labels) < 2 {
+		return &ValidationError{msg: "hostname must be a fully qualified domain name"}
+	}
+	for _, label := range labels {
+		if !labelPattern.MatchString(label) {
+			return &ValidationError{msg: fmt.Sprintf("invalid hostname label %q", label)}
+		}
+	}
+	return nil
+}
+
+func (s *service) checkTarget(userID uint, target Target) error {
+	if (target.DeployID == "") == (target.ProjectID == nil) {
+		return &ValidationError{msg: "exactly one of deploy_id and project_id is required"}
+	}
+
+	var count int64
+	var err error
+	if target.ProjectID != nil {
+		err = s.db.Model(&models.Project{}).Where("id = ? AND user_id = ?", *target.ProjectID, userID).Count(&count).Error
+	} else {
+		err = s.db.Model(&models.Deployment{}).Where("deploy_id = ? AND user_id = ?", target.DeployID, userID).Count(&count).Error
+	}
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrTargetNotFound
+	}
+	return nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
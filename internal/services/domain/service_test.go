@@ -0,0 +1,228 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/services"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// stubResolver answers TXT lookups from a map; names not in it are NXDOMAIN.
+type stubResolver struct {
+	records map[string][]string
+	err     error
+}
+
+func (r *stubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	values, ok := r.records[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return values, nil
+}
+
+func TestCheckRecord(t *testing.T) {
+	record := VerificationRecord{
+		Type:  "TXT",
+		Name:  "_gopher-challenge.www.example.com",
+		Value: "gopher-verification=abc123",
+	}
+
+	tests := []struct {
+		name       string
+		resolver   *stubResolver
+		wantErr    bool
+		wantFailed bool // the record is missing or wrong, as opposed to DNS being unavailable
+	}{
+		{
+			name:     "match",
+			resolver: &stubResolver{records: map[string][]string{record.Name: {record.Value}}},
+		},
+		{
+			name:     "match among other values",
+			resolver: &stubResolver{records: map[string][]string{record.Name: {"v=spf1 -all", " " + record.Value + " "}}},
+		},
+		{
+			name:       "mismatch",
+			resolver:   &stubResolver{records: map[string][]string{record.Name: {"gopher-verification=other"}}},
+			wantErr:    true,
+			wantFailed: true,
+		},
+		{
+			name:       "empty record",
+			resolver:   &stubResolver{records: map[string][]string{record.Name: {}}},
+			wantErr:    true,
+			wantFailed: true,
+		},
+		{
+			name:       "record on the domain itself",
+			resolver:   &stubResolver{records: map[string][]string{"www.example.com": {record.Value}}},
+			wantErr:    true,
+			wantFailed: true,
+		},
+		{
+			name:       "nxdomain",
+			resolver:   &stubResolver{},
+			wantErr:    true,
+			wantFailed: true,
+		},
+		{
+			name:     "temporary DNS failure",
+			resolver: &stubResolver{err: &net.DNSError{Err: "server misbehaving", Name: record.Name, IsTemporary: true}},
+			wantErr:  true,
+		},
+		{
+			name:     "resolver error",
+			resolver: &stubResolver{err: context.DeadlineExceeded},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &service{resolver: tt.resolver}
+			err := s.checkRecord(context.Background(), record)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkRecord = %v, want error %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, ErrVerificationFailed); got != tt.wantFailed {
+				t.Errorf("checkRecord = %v, ErrVerificationFailed %v, want %v", err, got, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestValidateHostname(t *testing.T) {
+	s := &service{baseHost: "localhost.dev"}
+
+	valid := []string{"example.com", "www.example.com", "a-b.example.co.uk", "xn--bcher-kva.example"}
+	for _, hostname := range valid {
+		if err := s.validateHostname(hostname); err != nil {
+			t.Errorf("validateHostname(%s) = %v", hostname, err)
+		}
+	}
+
+	invalid := []string{
+		"localhost",
+		"localhost.dev",
+		"app.localhost.dev",
+		"127.0.0.1",
+		"-bad.example.com",
+		"bad-.example.com",
+		"under_score.example.com",
+		"double..dot.com",
+	}
+	for _, hostname := range invalid {
+		var validationErr *ValidationError
+		if err := s.validateHostname(hostname); !errors.As(err, &validationErr) {
+			t.Errorf("validateHostname(%s) = %v, want a validation error", hostname, err)
+		}
+	}
+}
+
+// testService connects to TEST_DATABASE_DSN, skipping the test when unset.
+// The unique index on verified hostnames only exists in Postgres.
+func testService(t *testing.T, resolver TXTResolver) (*service, *gorm.DB) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Domain{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	// Cache invalidation fails against the closed port and is only logged
+	redis := services.NewRedisService("127.0.0.1:1")
+	return NewService(db, redis, resolver, "localhost.dev").(*service), db
+}
+
+// addDomain inserts an unverified domain directly, since AddDomain needs a
+// deployment or project to point it at.
+func addDomain(t *testing.T, db *gorm.DB, userID uint, hostname string) *models.Domain {
+	t.Helper()
+	domain := &models.Domain{UserID: userID, Hostname: hostname, DeployID: "test", Token: fmt.Sprintf("token%d", userID)}
+	if err := db.Create(domain).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Unscoped().Delete(domain) })
+	return domain
+}
+
+func TestVerifyDomain(t *testing.T) {
+	resolver := &stubResolver{records: map[string][]string{}}
+	s, db := testService(t, resolver)
+	hostname := fmt.Sprintf("verify-%d.example.com", time.Now().UnixNano())
+	domain := addDomain(t, db, 1, hostname)
+	record := s.Verification(domain)
+
+	if _, err := s.VerifyDomain(context.Background(), 1, hostname); !errors.Is(err, ErrVerificationFailed) {
+		t.Fatalf("VerifyDomain without a record = %v", err)
+	}
+
+	resolver.records[record.Name] = []string{"gopher-verification=wrong"}
+	if _, err := s.VerifyDomain(context.Background(), 1, hostname); !errors.Is(err, ErrVerificationFailed) {
+		t.Fatalf("VerifyDomain with a wrong record = %v", err)
+	}
+
+	resolver.records[record.Name] = []string{record.Value}
+	verified, err := s.VerifyDomain(context.Background(), 1, hostname)
+	if err != nil || verified.VerifiedAt == nil {
+		t.Fatalf("VerifyDomain = %+v, %v", verified, err)
+	}
+
+	var stored models.Domain
+	db.First(&stored, domain.ID)
+	if stored.VerifiedAt == nil {
+		t.Error("verification was not saved")
+	}
+}
+
+func TestVerifyDomainClaimedByAnotherUser(t *testing.T) {
+	resolver := &stubResolver{records: map[string][]string{}}
+	s, db := testService(t, resolver)
+	hostname := fmt.Sprintf("claimed-%d.example.com", time.Now().UnixNano())
+
+	first := addDomain(t, db, 1, hostname)
+	second := addDomain(t, db, 2, hostname)
+	name := s.Verification(first).Name
+	resolver.records[name] = []string{s.Verification(first).Value, s.Verification(second).Value}
+
+	if _, err := s.VerifyDomain(context.Background(), 1, hostname); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.VerifyDomain(context.Background(), 2, hostname); !errors.Is(err, ErrDomainClaimed) {
+		t.Fatalf("second verification = %v, want ErrDomainClaimed", err)
+	}
+
+	// Removing the verified domain frees the hostname
+	if err := s.RemoveDomain(context.Background(), 1, hostname); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.VerifyDomain(context.Background(), 2, hostname); err != nil {
+		t.Fatalf("verification after removal = %v", err)
+	}
+}
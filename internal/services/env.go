@@ -0,0 +1,48 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/utils"
+)
+
+// buildEnv returns the KEY=value pairs passed to a deployment's install and
+// build steps, in a stable order: the user's encrypted env vars for the
+// repository with the value for the deployment's target. Secret values are
+// registered with redact.
+func (s *DeployService) buildEnv(deployment *models.Deployment, redact *redactor) ([]string, error) {
+	vars := make(map[string]string)
+	var envVars []models.EnvVar
+	err := s.db.Where("user_id = ? AND repo_key = ?", deployment.UserID, utils.RepoKey(deployment.RepoURL)).Find(&envVars).Error
+	if err != nil {
+		return nil, classify(fmt.Errorf("Failed to load environment variables: %w", err), isTransientDatabaseError)
+	}
+
+	for _, envVar := range envVars {
+		sealed := envVar.ProductionValue
+		if deployment.Target == models.TargetPreview {
+			sealed = envVar.PreviewValue
+		}
+		if sealed == "" {
+			continue
+		}
+
+		value, err := s.secrets.Open(sealed)
+		if err != nil {
+			return nil, fmt.Errorf("Environment variable %s could not be decrypted", envVar.Key)
+		}
+		if envVar.Secret {
+			redact.add(value)
+		}
+		vars[envVar.Key] = value
+	}
+
+	env := make([]string, 0, len(vars))
+	for key, value := range vars {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+	return env, nil
+}
@@ -0,0 +1,236 @@
+package envvar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/secrets"
+	"deployment-platform/internal/utils"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEnvVarNotFound = errors.New("environment variable not found")
+	ErrEnvVarExists   = errors.New("environment variable already exists for this repository")
+)
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+const (
+	maxEnvVarsPerRepo = 200
+	maxValueSize      = 64 * 1024
+)
+
+// ValidationError is returned for input the client has to fix.
+type ValidationError struct {
+	msg string
+}
+
+func (e *ValidationError) Error() string { return e.msg }
+
+func invalid(format string, args ...interface{}) error {
+	return &ValidationError{msg: fmt.Sprintf(format, args...)}
+}
+
+// Values holds a variable's value per deployment target, nil when unset.
+type Values struct {
+	Production *string `json:"production,omitempty"`
+	Preview    *string `json:"preview,omitempty"`
+}
+
+type CreateEnvVarInput struct {
+	RepoURL string
+	Key     string
+	Values  Values
+	Secret  bool
+}
+
+// UpdateEnvVarInput changes only the values that are set; an empty string
+// unsets the value for that target. Secret variables cannot be made plain.
+type UpdateEnvVarInput struct {
+	Values Values
+	Secret *bool
+}
+
+type Service interface {
+	CreateEnvVar(ctx context.Context, userID uint, input CreateEnvVarInput) (*models.EnvVar, error)
+	GetEnvVar(ctx context.Context, envVarID, userID uint) (*models.EnvVar, error)
+	ListEnvVars(ctx context.Context, userID uint, repoURL string) ([]models.EnvVar, error)
+	UpdateEnvVar(ctx context.Context, envVarID, userID uint, input UpdateEnvVarInput) (*models.EnvVar, error)
+	DeleteEnvVar(ctx context.Context, envVarID, userID uint) error
+	// Reveal decrypts a variable's values; secret values are never revealed.
+	Reveal(envVar *models.EnvVar) (Values, error)
+}
+
+type service struct {
+	db  *gorm.DB
+	box *secrets.Box
+}
+
+func NewService(db *gorm.DB, box *secrets.Box) Service {
+	return &service{
+		db:  db,
+		box: box,
+	}
+}
+
+func (s *service) CreateEnvVar(ctx context.Context, userID uint, input CreateEnvVarInput) (*models.EnvVar, error) {
+	if !keyPattern.MatchString(input.Key) {
+		return nil, invalid("invalid key %q", input.Key)
+	}
+	if input.Values.Production == nil && input.Values.Preview == nil {
+		return nil, invalid("at least one of production or preview is required")
+	}
+
+	envVar := &models.EnvVar{
+		UserID:  userID,
+		RepoURL: input.RepoURL,
+		RepoKey: utils.RepoKey(input.RepoURL),
+		Key:     input.Key,
+		Secret:  input.Secret,
+	}
+	if err := s.setValues(envVar, input.Values); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	err := s.db.Model(&models.EnvVar{}).Where("user_id = ? AND repo_key = ?", userID, envVar.RepoKey).Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxEnvVarsPerRepo {
+		return nil, invalid("at most %d environment variables per repository", maxEnvVarsPerRepo)
+	}
+
+	if err := s.db.Create(envVar).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrEnvVarExists
+		}
+		return nil, err
+	}
+	return envVar, nil
+}
+
+func (s *service) GetEnvVar(ctx context.Context, envVarID, userID uint) (*models.EnvVar, error) {
+	var envVar models.EnvVar
+	if err := s.db.Where("id = ? AND user_id = ?", envVarID, userID).First(&envVar).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEnvVarNotFound
+		}
+		return nil, err
+	}
+	return &envVar, nil
+}
+
+// ListEnvVars returns the user's variables, only those of one repository
+// when repoURL is set.
+func (s *service) ListEnvVars(ctx context.Context, userID uint, repoURL string) ([]models.EnvVar, error) {
+	query := s.db.Where("user_id = ?", userID)
+	if repoURL != "" {
+		query = query.Where("repo_key = ?", utils.RepoKey(repoURL))
+	}
+
+	var envVars []models.EnvVar
+	if err := query.Order("repo_key, key").Find(&envVars).Error; err != nil {
+		return nil, err
+	}
+	return envVars, nil
+}
+
+func (s *service) UpdateEnvVar(ctx context.Context, envVarID, userID uint, input UpdateEnvVarInput) (*models.EnvVar, error) {
+	envVar, err := s.GetEnvVar(ctx, envVarID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Secret != nil {
+		if envVar.Secret && !*input.Secret {
+			return nil, invalid("secret environment variables cannot be made plain, delete and recreate it instead")
+		}
+		envVar.Secret = *input.Secret
+	}
+	if err := s.setValues(envVar, input.Values); err != nil {
+		return nil, err
+	}
+	if envVar.ProductionValue == "" && envVar.PreviewValue == "" {
+		return nil, invalid("at least one of production or preview must stay set")
+	}
+
+	if err := s.db.Save(envVar).Error; err != nil {
+		return nil, err
+	}
+	return envVar, nil
+}
+
+func (s *service) DeleteEnvVar(ctx context.Context, envVarID, userID uint) error {
+	envVar, err := s.GetEnvVar(ctx, envVarID, userID)
+	if err != nil {
+		return err
+	}
+	return s.db.Delete(envVar).Error
+}
+
+func (s *service) Reveal(envVar *models.EnvVar) (Values, error) {
+	var values Values
+	if envVar.Secret {
+		return values, nil
+	}
+
+	var err error
+	if values.Production, err = s.open(envVar.ProductionValue); err != nil {
+		return values, err
+	}
+	if values.Preview, err = s.open(envVar.PreviewValue); err != nil {
+		return values, err
+	}
+	return values, nil
+}
+
+// setValues seals the values that are set; empty strings unset a target.
+func (s *service) setValues(envVar *models.EnvVar, values Values) error {
+	for _, v := range []struct {
+		value *string
+		dest  *string
+	}{
+		{values.Production, &envVar.ProductionValue},
+		{values.Preview, &envVar.PreviewValue},
+	} {
+		if v.value == nil {
+			continue
+		}
+		if *v.value == "" {
+			*v.dest = ""
+			continue
+		}
+		if len(*v.value) > maxValueSize {
+			return invalid("value of %s exceeds %d bytes", envVar.Key, maxValueSize)
+		}
+		sealed, err := s.box.Seal(*v.value)
+		if err != nil {
+			return err
+		}
+		*v.dest = sealed
+	}
+	return nil
+}
+
+func (s *service) open(sealed string) (*string, error) {
+	if sealed == "" {
+		return nil, nil
+	}
+	value, err := s.box.Open(sealed)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
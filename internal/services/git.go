@@ -0,0 +1,372 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/utils"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"gorm.io/gorm"
+)
+
+const remoteName = "origin"
+
+// shaPattern matches full and abbreviated commit hashes.
+var shaPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+// errRefNotFound is returned when a requested ref is neither a branch, a
+// tag nor a commit of the repository.
+var errRefNotFound = errors.New("ref not found")
+
+// sourceInfo describes the commit a deployment was built from.
+type sourceInfo struct {
+	CommitSHA     string
+	CommitMessage string
+	CommitAuthor  string
+	Branch        string
+}
+
+// cloneRepo fetches the deployment's ref into destPath and checks it out,
+// then records the resolved commit on the deployment. Secrets of the git
+// credential it uses are registered with redact.
+func (s *DeployService) cloneRepo(ctx context.Context, deployment *models.Deployment, destPath string, redact *redactor) error {
+	repoURL, auth, err := s.gitAuth(deployment, redact)
+	if err != nil {
+		return err
+	}
+
+	info, err := fetchSource(ctx, deployment, destPath, repoURL, auth)
+	if err != nil {
+		return err
+	}
+
+	deployment.CommitSHA = info.CommitSHA
+	deployment.CommitMessage = info.CommitMessage
+	deployment.CommitAuthor = info.CommitAuthor
+	if deployment.Branch == "" {
+		deployment.Branch = info.Branch
+	}
+	return nil
+}
+
+// gitAuth loads the deployment's git credential, if any, and returns the
+// remote URL rewritten for the credential's transport with matching auth.
+func (s *DeployService) gitAuth(deployment *models.Deployment, redact *redactor) (string, transport.AuthMethod, error) {
+	if deployment.CredentialID == nil {
+		return deployment.RepoURL, nil, nil
+	}
+
+	var cred models.GitCredential
+	err := s.db.Where("id = ? AND user_id = ?", *deployment.CredentialID, deployment.UserID).First(&cred).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil, errors.New("git credential not found, it may have been deleted")
+		}
+		return "", nil, transient(err)
+	}
+	secret, err := s.secrets.Open(cred.Secret)
+	if err != nil {
+		return "", nil, fmt.Errorf("git credential %q could not be decrypted", cred.Name)
+	}
+	redact.add(secret)
+
+	switch cred.Type {
+	case models.CredentialSSH:
+		user := cred.Username
+		if user == "" {
+			user = "git"
+		}
+		keys, err := gitssh.NewPublicKeys(user, []byte(secret), "")
+		if err != nil {
+			return "", nil, fmt.Errorf("git credential %q has an invalid key", cred.Name)
+		}
+		if s.opts.GitKnownHosts != "" {
+			keys.HostKeyCallback, err = gitssh.NewKnownHostsCallback(s.opts.GitKnownHosts)
+			if err != nil {
+				return "", nil, fmt.Errorf("loading known hosts: %w", err)
+			}
+		}
+		return utils.SSHURL(deployment.RepoURL, user), keys, nil
+	default:
+		// Hosts ignore the username for tokens but require one to be present
+		user := cred.Username
+		if user == "" {
+			user = "git"
+		}
+		return utils.HTTPSURL(deployment.RepoURL), &githttp.BasicAuth{Username: user, Password: secret}, nil
+	}
+}
+
+// fetchSource does a shallow fetch of exactly one commit: the pinned commit
+// when there is one, otherwise the requested branch, tag or commit, or the
+// remote's default branch when no ref was given.
+func fetchSource(ctx context.Context, deployment *models.Deployment, destPath, repoURL string, auth transport.AuthMethod) (*sourceInfo, error) {
+	repo, err := git.PlainInit(destPath, false)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := repo.CreateRemote(&gitconfig.RemoteConfig{
+		Name: remoteName,
+		URLs: []string{repoURL},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info := &sourceInfo{Branch: deployment.Branch}
+	var target plumbing.Hash
+
+	switch {
+	case deployment.CommitSHA != "":
+		// Pinned by an earlier attempt or a push, build exactly that commit
+		target, err = fetchCommit(ctx, repo, auth, deployment.CommitSHA, deployment.Branch)
+	case deployment.Branch != "":
+		target, err = fetchRef(ctx, repo, auth, plumbing.NewBranchReferenceName(deployment.Branch))
+	default:
+		var refs []*plumbing.Reference
+		refs, err = remote.ListContext(ctx, &git.ListOptions{Auth: auth})
+		if err != nil {
+			return nil, err
+		}
+		var name plumbing.ReferenceName
+		name, err = resolveRef(refs, deployment.Ref)
+		if err == nil {
+			if name.IsBranch() {
+				info.Branch = name.Short()
+			}
+			target, err = fetchRef(ctx, repo, auth, name)
+		} else if errors.Is(err, errRefNotFound) && shaPattern.MatchString(deployment.Ref) {
+			target, err = fetchCommit(ctx, repo, auth, deployment.Ref, "")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := peelCommit(repo, target)
+	if err != nil {
+		return nil, err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: commit.Hash, Force: true}); err != nil {
+		return nil, fmt.Errorf("checking out %s: %w", commit.Hash, err)
+	}
+
+	info.CommitSHA = commit.Hash.String()
+	info.CommitMessage = strings.TrimSpace(commit.Message)
+	info.CommitAuthor = fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email)
+	return info, nil
+}
+
+// resolveRef picks the remote ref to deploy. An empty ref means the remote's
+// default branch; branches win over tags of the same name.
+func resolveRef(refs []*plumbing.Reference, ref string) (plumbing.ReferenceName, error) {
+	if ref == "" {
+		for _, r := range refs {
+			if r.Name() == plumbing.HEAD && r.Type() == plumbing.SymbolicReference {
+				return r.Target(), nil
+			}
+		}
+		// Servers that don't advertise the HEAD symref: use the branch HEAD points at
+		var head plumbing.Hash
+		for _, r := range refs {
+			if r.Name() == plumbing.HEAD {
+				head = r.Hash()
+			}
+		}
+		var match plumbing.ReferenceName
+		for _, r := range refs {
+			if !r.Name().IsBranch() || head.IsZero() || r.Hash() != head {
+				continue
+			}
+			if short := r.Name().Short(); short == "main" || short == "master" {
+				return r.Name(), nil
+			}
+			if match == "" {
+				match = r.Name()
+			}
+		}
+		if match == "" {
+			return "", fmt.Errorf("could not determine the default branch")
+		}
+		return match, nil
+	}
+
+	candidates := []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName(ref),
+		plumbing.NewTagReferenceName(ref),
+		plumbing.ReferenceName(ref),
+	}
+	for _, name := range candidates {
+		for _, r := range refs {
+			if r.Name() == name {
+				return name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %q", errRefNotFound, ref)
+}
+
+// fetchRef shallow-fetches a single remote ref and returns the object it points at.
+func fetchRef(ctx context.Context, repo *git.Repository, auth transport.AuthMethod, name plumbing.ReferenceName) (plumbing.Hash, error) {
+	spec := gitconfig.RefSpec(fmt.Sprintf("+%s:%s", name, name))
+	err := repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: remoteName,
+		Auth:       auth,
+		RefSpecs:   []gitconfig.RefSpec{spec},
+		Depth:      1,
+		Tags:       git.NoTags,
+		Progress:   os.Stdout,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		if errors.Is(err, git.NoMatchingRefSpecError{}) {
+			return plumbing.ZeroHash, fmt.Errorf("%w: %q", errRefNotFound, name.Short())
+		}
+		return plumbing.ZeroHash, err
+	}
+
+	ref, err := repo.Reference(name, true)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return ref.Hash(), nil
+}
+
+// fetchCommit fetches a single commit by hash. Most hosts allow that for full
+// hashes; otherwise, and for abbreviated hashes, it falls back to fetching
+// the branch (or every branch and tag) with full history.
+func fetchCommit(ctx context.Context, repo *git.Repository, auth transport.AuthMethod, sha, branch string) (plumbing.Hash, error) {
+	if len(sha) == 40 {
+		hash := plumbing.NewHash(sha)
+		spec := gitconfig.RefSpec(fmt.Sprintf("%s:refs/deploy", sha))
+		err := repo.FetchContext(ctx, &git.FetchOptions{
+			RemoteName: remoteName,
+			Auth:       auth,
+			RefSpecs:   []gitconfig.RefSpec{spec},
+			Depth:      1,
+			Tags:       git.NoTags,
+			Progress:   os.Stdout,
+		})
+		if err == nil || errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return hash, nil
+		}
+		if ctx.Err() != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	specs := []gitconfig.RefSpec{"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*"}
+	if branch != "" {
+		specs = []gitconfig.RefSpec{gitconfig.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", branch, branch))}
+	}
+	err := repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: remoteName,
+		Auth:       auth,
+		RefSpecs:   specs,
+		Tags:       git.NoTags,
+		Progress:   os.Stdout,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return plumbing.ZeroHash, err
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(sha))
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("%w: %q", errRefNotFound, sha)
+	}
+	return *hash, nil
+}
+
+// unchangedSince reports whether paths are identical in the checked-out
+// commit and prevSHA. Only the previous commit itself is fetched, unless the
+// clone already has it, since comparing tree hashes needs no history.
+func (s *DeployService) unchangedSince(ctx context.Context, deployment *models.Deployment, repoDir, prevSHA string, paths []string, redact *redactor) (bool, error) {
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil {
+		return false, err
+	}
+
+	previous, err := repo.CommitObject(plumbing.NewHash(prevSHA))
+	if err != nil {
+		_, auth, err := s.gitAuth(deployment, redact)
+		if err != nil {
+			return false, err
+		}
+		spec := gitconfig.RefSpec(fmt.Sprintf("%s:refs/previous", prevSHA))
+		err = repo.FetchContext(ctx, &git.FetchOptions{
+			RemoteName: remoteName,
+			Auth:       auth,
+			RefSpecs:   []gitconfig.RefSpec{spec},
+			Depth:      1,
+			Tags:       git.NoTags,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return false, err
+		}
+		if previous, err = repo.CommitObject(plumbing.NewHash(prevSHA)); err != nil {
+			return false, err
+		}
+	}
+
+	current, err := repo.CommitObject(plumbing.NewHash(deployment.CommitSHA))
+	if err != nil {
+		return false, err
+	}
+	for _, p := range paths {
+		a, err := pathHash(current, p)
+		if err != nil {
+			return false, err
+		}
+		b, err := pathHash(previous, p)
+		if err != nil {
+			return false, err
+		}
+		if a != b {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// pathHash returns the hash of the file or tree at p in a commit, or the
+// zero hash when it doesn't exist.
+func pathHash(commit *object.Commit, p string) (plumbing.Hash, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if p == "." {
+		return tree.Hash, nil
+	}
+	entry, err := tree.FindEntry(p)
+	if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return entry.Hash, nil
+}
+
+// peelCommit returns the commit behind hash, following annotated tags.
+func peelCommit(repo *git.Repository, hash plumbing.Hash) (*object.Commit, error) {
+	if tag, err := repo.TagObject(hash); err == nil {
+		return tag.Commit()
+	}
+	return repo.CommitObject(hash)
+}
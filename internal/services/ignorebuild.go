@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/services/builder"
+)
+
+// errBuildSkipped ends a deployment whose build inputs match the last
+// deployment of the same repository, root directory and target.
+var errBuildSkipped = errors.New("nothing changed since the last deployment")
+
+// unchangedDeployment returns the last successful deployment that was built
+// from the same files as this one, or nil when the deployment has to be
+// built. Redeploys of the same commit are always built, and any failure to
+// compare simply means building.
+func (s *DeployService) unchangedDeployment(ctx context.Context, deployment *models.Deployment, repoDir string, plan *builder.Plan, redact *redactor) *models.Deployment {
+	if deployment.CommitSHA == "" {
+		return nil
+	}
+
+	query := s.db.Where("user_id = ? AND repo_url = ? AND root_directory = ? AND target = ? AND branch = ? AND status = ? AND commit_sha <> '' AND created_at < ?",
+		deployment.UserID, deployment.RepoURL, deployment.RootDirectory, deployment.Target, deployment.Branch, "deployed", deployment.CreatedAt)
+	if deployment.ProjectID != nil {
+		query = query.Where("project_id = ?", *deployment.ProjectID)
+	} else {
+		query = query.Where("project_id IS NULL")
+	}
+	var previous models.Deployment
+	if err := query.Order("created_at DESC").First(&previous).Error; err != nil {
+		return nil
+	}
+	if previous.CommitSHA == deployment.CommitSHA {
+		return nil
+	}
+
+	unchanged, err := s.unchangedSince(ctx, deployment, repoDir, previous.CommitSHA, buildInputs(deployment.RootDirectory, plan), redact)
+	if err != nil {
+		log.Printf("Could not compare %s with %s, building: %v", deployment.DeployID, previous.DeployID, err)
+		return nil
+	}
+	if !unchanged {
+		return nil
+	}
+	return &previous
+}
+
+// buildInputs lists the repository paths a build depends on: the root
+// directory, gopher.json and, for workspace packages, the files at the
+// workspace root that decide what gets installed.
+func buildInputs(rootDir string, plan *builder.Plan) []string {
+	if rootDir == "." {
+		return []string{"."}
+	}
+	inputs := []string{rootDir, builder.ConfigFileName}
+	if plan.InstallDir != "" {
+		workspace := path.Join(rootDir, plan.InstallDir)
+		inputs = append(inputs, path.Join(workspace, "package.json"), path.Join(workspace, "pnpm-workspace.yaml"))
+		if plan.PackageManager != nil && plan.PackageManager.Lockfile != "" {
+			inputs = append(inputs, path.Join(workspace, plan.PackageManager.Lockfile))
+		}
+	}
+	return inputs
+}
+
+// openOutputDir opens dir, relative to the workspace, without following
+// symlinks out of the workspace.
+func openOutputDir(workspace, dir string) (*os.Root, error) {
+	root, err := os.OpenRoot(workspace)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	return root.OpenRoot(dir)
+}
+
+// insideDir reports whether dir is a directory within root once symlinks
+// are resolved, so a repository can't point the build outside its clone.
+func insideDir(root, dir string) bool {
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return false
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+		return false
+	}
+	return resolved == resolvedRoot || strings.HasPrefix(resolved, resolvedRoot+string(filepath.Separator))
+}
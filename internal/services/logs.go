@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+const logChannelPrefix = "deployments:logs:"
+
+// LogPublisher fans build output out to whoever is following a deployment.
+type LogPublisher interface {
+	BroadcastLog(deploymentID string, logMsg string)
+}
+
+// RedisLogPublisher publishes build output on Redis so that API instances,
+// which own the WebSocket connections, can relay it from separate workers.
+type RedisLogPublisher struct {
+	redis *RedisService
+}
+
+func NewRedisLogPublisher(redis *RedisService) *RedisLogPublisher {
+	return &RedisLogPublisher{redis: redis}
+}
+
+func (p *RedisLogPublisher) BroadcastLog(deploymentID string, logMsg string) {
+	if err := p.redis.Publish(context.Background(), logChannelPrefix+deploymentID, logMsg); err != nil {
+		log.Printf("Failed to publish log for %s: %v", deploymentID, err)
+	}
+}
+
+// RelayLogs forwards log lines published by any worker to the local
+// WebSocket hub until ctx is cancelled.
+func RelayLogs(ctx context.Context, redis *RedisService, hub LogPublisher) {
+	sub := redis.PSubscribe(ctx, logChannelPrefix+"*")
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			hub.BroadcastLog(strings.TrimPrefix(msg.Channel, logChannelPrefix), msg.Payload)
+		}
+	}
+}
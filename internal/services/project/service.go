@@ -0,0 +1,381 @@
+package project
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/services"
+	"deployment-platform/internal/services/alias"
+	"deployment-platform/internal/services/builder"
+	"deployment-platform/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrProjectNotFound = errors.New("project not found")
+	ErrSlugTaken       = errors.New("slug is already in use")
+)
+
+// ValidationError is returned for input the client has to fix.
+type ValidationError struct {
+	msg string
+}
+
+func (e *ValidationError) Error() string { return e.msg }
+
+func invalid(format string, args ...interface{}) error {
+	return &ValidationError{msg: fmt.Sprintf(format, args...)}
+}
+
+type CreateProjectInput struct {
+	Name          string
+	Slug          string
+	RepoURL       string
+	DefaultBranch string
+	CredentialID  *uint
+	BuildSettings *models.ProjectConfig
+}
+
+// UpdateProjectInput changes only the fields that are set. A CredentialID
+// of 0 detaches the project's git credential.
+type UpdateProjectInput struct {
+	Name          *string
+	Slug          *string
+	RepoURL       *string
+	DefaultBranch *string
+	CredentialID  *uint
+	BuildSettings *models.ProjectConfig
+}
+
+type Service interface {
+	CreateProject(ctx context.Context, userID uint, input CreateProjectInput) (*models.Project, error)
+	GetProject(ctx context.Context, projectID, userID uint) (*models.Project, error)
+	ListProjects(ctx context.Context, userID uint) ([]models.Project, error)
+	UpdateProject(ctx context.Context, projectID, userID uint, input UpdateProjectInput) (*models.Project, error)
+	DeleteProject(ctx context.Context, projectID, userID uint) error
+	ListDeployments(ctx context.Context, projectID, userID uint) ([]models.Deployment, error)
+	WebhookSecret(ctx context.Context, projectID, userID uint, rotate bool) (string, error)
+	ProjectURL(project *models.Project) string
+}
+
+type service struct {
+	db            *gorm.DB
+	deployService *services.DeployService
+	redis         *services.RedisService
+	baseDomain    string
+}
+
+func NewService(db *gorm.DB, deployService *services.DeployService, redis *services.RedisService, baseDomain string) Service {
+	return &service{
+		db:            db,
+		deployService: deployService,
+		redis:         redis,
+		baseDomain:    baseDomain,
+	}
+}
+
+func (s *service) CreateProject(ctx context.Context, userID uint, input CreateProjectInput) (*models.Project, error) {
+	slug := input.Slug
+	if slug == "" {
+		slug = slugify(input.Name)
+	}
+	if err := validateSlug(slug); err != nil {
+		return nil, err
+	}
+	if err := validateSettings(input.BuildSettings); err != nil {
+		return nil, err
+	}
+	if err := s.checkCredential(userID, input.CredentialID); err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	project := &models.Project{
+		UserID:        userID,
+		WebhookSecret: secret,
+		Name:          input.Name,
+		Slug:          slug,
+		RepoURL:       input.RepoURL,
+		RepoKey:       utils.RepoKey(input.RepoURL),
+		DefaultBranch: input.DefaultBranch,
+		CredentialID:  input.CredentialID,
+		BuildSettings: input.BuildSettings,
+	}
+	if project.DefaultBranch == "" {
+		project.DefaultBranch = "main"
+	}
+
+	// The project's slug is reserved as its production alias straight
+	// away; the alias gets a target once a production deployment succeeds
+	prodAlias := &models.Alias{
+		UserID:   userID,
+		Name:     slug,
+		Hostname: alias.Hostname(slug, s.baseDomain),
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(project).Error; err != nil {
+			return err
+		}
+		prodAlias.ProjectID = &project.ID
+		return tx.Create(prodAlias).Error
+	})
+	if err != nil {
+		if alias.IsNameTaken(err) {
+			return nil, ErrSlugTaken
+		}
+		return nil, err
+	}
+
+	s.redis.InvalidateAlias(ctx, prodAlias.Hostname)
+	return project, nil
+}
+
+func (s *service) GetProject(ctx context.Context, projectID, userID uint) (*models.Project, error) {
+	var project models.Project
+	if err := s.db.Where("id = ? AND user_id = ?", projectID, userID).First(&project).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProjectNotFound
+		}
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (s *service) ListProjects(ctx context.Context, userID uint) ([]models.Project, error) {
+	var projects []models.Project
+	if err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+func (s *service) UpdateProject(ctx context.Context, projectID, userID uint, input UpdateProjectInput) (*models.Project, error) {
+	project, err := s.GetProject(ctx, projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+	oldSlug := project.Slug
+
+	if input.Name != nil {
+		project.Name = *input.Name
+	}
+	if input.Slug != nil {
+		if err := validateSlug(*input.Slug); err != nil {
+			return nil, err
+		}
+		project.Slug = *input.Slug
+	}
+	if input.RepoURL != nil {
+		project.RepoURL = *input.RepoURL
+		project.RepoKey = utils.RepoKey(*input.RepoURL)
+	}
+	if input.DefaultBranch != nil {
+		project.DefaultBranch = *input.DefaultBranch
+	}
+	if input.CredentialID != nil {
+		if *input.CredentialID == 0 {
+			project.CredentialID = nil
+		} else {
+			if err := s.checkCredential(userID, input.CredentialID); err != nil {
+				return nil, err
+			}
+			project.CredentialID = input.CredentialID
+		}
+	}
+	if input.BuildSettings != nil {
+		project.BuildSettings = input.BuildSettings
+	}
+	if err := validateSettings(project.BuildSettings); err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(project).Error; err != nil {
+			return err
+		}
+		if project.Slug == oldSlug {
+			return nil
+		}
+		return tx.Model(&models.Alias{}).Where("project_id = ?", project.ID).Updates(map[string]interface{}{
+			"name":     project.Slug,
+			"hostname": alias.Hostname(project.Slug, s.baseDomain),
+		}).Error
+	})
+	if err != nil {
+		if alias.IsNameTaken(err) {
+			return nil, ErrSlugTaken
+		}
+		return nil, err
+	}
+
+	if project.Slug != oldSlug {
+		s.redis.InvalidateAlias(ctx, alias.Hostname(oldSlug, s.baseDomain))
+		s.redis.InvalidateAlias(ctx, alias.Hostname(project.Slug, s.baseDomain))
+		// Custom domains cache the alias hostname they follow
+		s.invalidateDomains(ctx, project.ID)
+	}
+	return project, nil
+}
+
+// DeleteProject deletes a project together with all of its deployments,
+// tearing each one down like DELETE /deployments/:id does.
+func (s *service) DeleteProject(ctx context.Context, projectID, userID uint) error {
+	project, err := s.GetProject(ctx, projectID, userID)
+	if err != nil {
+		return err
+	}
+
+	var deployments []models.Deployment
+	if err := s.db.Where("project_id = ?", project.ID).Find(&deployments).Error; err != nil {
+		return err
+	}
+	var domains []models.Domain
+	if err := s.db.Where("project_id = ?", project.ID).Find(&domains).Error; err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ?", project.ID).Delete(&models.Deployment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", project.ID).Delete(&models.Alias{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", project.ID).Delete(&models.Domain{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(project).Error
+	})
+	if err != nil {
+		return err
+	}
+	s.redis.InvalidateAlias(ctx, alias.Hostname(project.Slug, s.baseDomain))
+	for _, domain := range domains {
+		s.redis.InvalidateDomain(ctx, domain.Hostname)
+	}
+
+	var cleanupErr error
+	for _, deployment := range deployments {
+		if err := s.deployService.PurgeDeployment(ctx, deployment.DeployID); err != nil {
+			log.Printf("Failed to purge deployment %s: %v", deployment.DeployID, err)
+			cleanupErr = err
+		}
+	}
+	if cleanupErr != nil {
+		return fmt.Errorf("project deleted but cleanup failed: %w", cleanupErr)
+	}
+	return nil
+}
+
+func (s *service) ListDeployments(ctx context.Context, projectID, userID uint) ([]models.Deployment, error) {
+	project, err := s.GetProject(ctx, projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var deployments []models.Deployment
+	if err := s.db.Where("project_id = ?", project.ID).Order("created_at DESC").Find(&deployments).Error; err != nil {
+		return nil, err
+	}
+	return deployments, nil
+}
+
+// WebhookSecret returns the secret push webhooks for the project are signed
+// with. rotate replaces it with a new one.
+func (s *service) WebhookSecret(ctx context.Context, projectID, userID uint, rotate bool) (string, error) {
+	project, err := s.GetProject(ctx, projectID, userID)
+	if err != nil {
+		return "", err
+	}
+	if !rotate {
+		return project.WebhookSecret, nil
+	}
+
+	secret, err := utils.NewSecret()
+	if err != nil {
+		return "", err
+	}
+	if err := s.db.Model(project).Update("webhook_secret", secret).Error; err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// ProjectURL is the stable URL serving the project's production deployment.
+func (s *service) ProjectURL(project *models.Project) string {
+	return fmt.Sprintf("http://%s.%s", project.Slug, s.baseDomain)
+}
+
+func (s *service) invalidateDomains(ctx context.Context, projectID uint) {
+	var hostnames []string
+	if err := s.db.Model(&models.Domain{}).Where("project_id = ?", projectID).Pluck("hostname", &hostnames).Error; err != nil {
+		log.Printf("Failed to list domains of project %d: %v", projectID, err)
+		return
+	}
+	for _, hostname := range hostnames {
+		s.redis.InvalidateDomain(ctx, hostname)
+	}
+}
+
+func validateSlug(slug string) error {
+	if err := alias.ValidateName(slug); err != nil {
+		return invalid("invalid slug: %v", err)
+	}
+	return nil
+}
+
+// checkCredential makes sure a git credential belongs to the user.
+func (s *service) checkCredential(userID uint, credentialID *uint) error {
+	if credentialID == nil {
+		return nil
+	}
+	var count int64
+	err := s.db.Model(&models.GitCredential{}).Where("id = ? AND user_id = ?", *credentialID, userID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return invalid("git credential %d not found", *credentialID)
+	}
+	return nil
+}
+
+func validateSettings(settings *models.ProjectConfig) error {
+	if settings != nil {
+		if err := builder.ValidateConfig(settings); err != nil {
+			return invalid("invalid build_settings: %v", err)
+		}
+	}
+	return nil
+}
+
+// slugify derives a default slug from a project name.
+func slugify(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			hyphen = false
+		case b.Len() > 0 && !hyphen:
+			b.WriteByte('-')
+			hyphen = true
+		}
+	}
+
+	slug := strings.TrimSuffix(b.String(), "-")
+	if len(slug) > 48 {
+		slug = strings.TrimSuffix(slug[:48], "-")
+	}
+	return slug
+}
@@ -1,74 +0,0 @@
-package services
-
-import (
-	"log"
-
-	"github.com/streadway/amqp"
-)
-
-type RabbitMQ struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
-}
-
-func NewRabbitMQ(url string) (*RabbitMQ, error) {
-	conn, err := amqp.Dial(url)
-	if err != nil {
-		return nil, err
-	}
-
-	ch, err := conn.Channel()
-	if err != nil {
-		return nil, err
-	}
-
-	// Declare queue
-	_, err = ch.QueueDeclare(
-		"deployments",
-		true,  // durable
-		false, // delete when unused
-		false, // exclusive
-		false, // no-wait
-		nil,   // arguments
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	log.Println("RabbitMQ connected successfully")
-
-	return &RabbitMQ{
-		conn:    conn,
-		channel: ch,
-	}, nil
-}
-
-func (r *RabbitMQ) Publish(queueName string, body []byte) error {
-	return r.channel.Publish(
-		"",        // exchange
-		queueName, // routing key
-		false,     // mandatory
-		false,     // immediate
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
-		},
-	)
-}
-
-func (r *RabbitMQ) Consume(queueName string) (<-chan amqp.Delivery, error) {
-	return r.channel.Consume(
-		queueName,
-		"",    // consumer
-		false, // auto-ack
-		false, // exclusive
-		false, // no-local
-		false, // no-wait
-		nil,   // args
-	)
-}
-
-func (r *RabbitMQ) Close() {
-	r.channel.Close()
-	r.conn.Close()
-}
\ No newline at end of file
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"strings"
+	"sync"
+)
+
+// redactedPlaceholder replaces secret values in logs and error messages.
+const redactedPlaceholder = "[REDACTED]"
+
+// redactor masks the secrets a build has access to before its output is
+// stored in BuildLog or ErrorMsg or streamed to log subscribers.
+type redactor struct {
+	mu       sync.RWMutex
+	replacer *strings.Replacer
+	values   []string
+}
+
+// add registers secret values; very short values are ignored, since masking
+// them would mangle unrelated output.
+func (r *redactor) add(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, v := range values {
+		if len(v) < 4 {
+			continue
+		}
+		r.values = append(r.values, v)
+		// Multi-line secrets such as private keys are also masked line by line
+		for _, line := range strings.Split(v, "\n") {
+			if line = strings.TrimSpace(line); line != v && len(line) >= 8 {
+				r.values = append(r.values, line)
+			}
+		}
+	}
+
+	pairs := make([]string, 0, 2*len(r.values))
+	for _, v := range r.values {
+		pairs = append(pairs, v, redactedPlaceholder)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+func (r *redactor) redact(s string) string {
+	if r == nil {
+		return s
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// safeCut returns how much of s, the start of a stream, can be masked and
+// released now: the rest could be the beginning of a secret that continues
+// in the next write, and a secret is never cut through the middle.
+func (r *redactor) safeCut(s string) int {
+	if r == nil {
+		return len(s)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	longest := 0
+	for _, v := range r.values {
+		longest = max(longest, len(v))
+	}
+	if longest == 0 {
+		return len(s)
+	}
+
+	cut := len(s) - (longest - 1)
+	for moved := true; moved && cut > 0; {
+		moved = false
+		for _, v := range r.values {
+			// The first occurrence starting within len(v) before cut
+			from := max(cut-len(v)+1, 0)
+			i := strings.Index(s[from:min(cut+len(v)-1, len(s))], v)
+			if i >= 0 && from+i < cut {
+				cut, moved = from+i, true
+			}
+		}
+	}
+	return max(cut, 0)
+}
+
+// redactError masks secrets in err's message while keeping it unwrappable,
+// so retry classification still works.
+func (r *redactor) redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := r.redact(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
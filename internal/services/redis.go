@@ -2,6 +2,8 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"log"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -37,6 +39,10 @@ func (s *RedisService) Get(ctx context.Context, key string) ([]byte, error) {
 	return s.client.Get(ctx, key).Bytes()
 }
 
+func (s *RedisService) Delete(ctx context.Context, keys ...string) error {
+	return s.client.Del(ctx, keys...).Err()
+}
+
 func (s *RedisService) SetContentType(ctx context.Context, key string, contentType string, ttl time.Duration) error {
 	return s.client.Set(ctx, key+":content-type", contentType, ttl).Err()
 }
@@ -44,3 +50,90 @@ func (s *RedisService) SetContentType(ctx context.Context, key string, contentTy
 func (s *RedisService) GetContentType(ctx context.Context, key string) (string, error) {
 	return s.client.Get(ctx, key+":content-type").Result()
 }
+
+func (s *RedisService) Publish(ctx context.Context, channel string, message string) error {
+	return s.client.Publish(ctx, channel, message).Err()
+}
+
+func (s *RedisService) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return s.client.Subscribe(ctx, channels...)
+}
+
+func (s *RedisService) PSubscribe(ctx context.Context, patterns ...string) *redis.PubSub {
+	return s.client.PSubscribe(ctx, patterns...)
+}
+
+func (s *RedisService) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// DeleteByPattern removes every key matching a glob pattern. It walks the
+// keyspace with SCAN so large caches don't block the server like KEYS would.
+func (s *RedisService) DeleteByPattern(ctx context.Context, pattern string) (int, error) {
+	deleted := 0
+	iter := s.client.Scan(ctx, 0, pattern, 500).Iterator()
+
+	batch := make([]string, 0, 500)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := s.client.Unlink(ctx, batch...).Result()
+		deleted += int(n)
+		batch = batch[:0]
+		return err
+	}
+
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+	return deleted, flush()
+}
+
+// DeploymentCacheKey is where the request handler caches a served file.
+func DeploymentCacheKey(deployID, path string) string {
+	return fmt.Sprintf("deploy:%s:%s", deployID, path)
+}
+
+// DeploymentStateKey caches whether a deployment can be served at all.
+func DeploymentStateKey(deployID string) string {
+	return fmt.Sprintf("deploy:%s:state", deployID)
+}
+
+// DeploymentCachePattern matches every cache key belonging to a deployment.
+func DeploymentCachePattern(deployID string) string {
+	return fmt.Sprintf("deploy:%s:*", deployID)
+}
+
+// AliasCacheKey caches the deploy ID a hostname alias points at.
+func AliasCacheKey(hostname string) string {
+	return fmt.Sprintf("alias:%s", hostname)
+}
+
+// DomainCacheKey caches where a custom domain is routed.
+func DomainCacheKey(hostname string) string {
+	return fmt.Sprintf("domain:%s", hostname)
+}
+
+// InvalidateAlias drops the cached target of an alias hostname so request
+// handlers pick up the change immediately.
+func (s *RedisService) InvalidateAlias(ctx context.Context, hostname string) {
+	if err := s.Delete(ctx, AliasCacheKey(hostname)); err != nil {
+		log.Printf("Failed to invalidate alias cache for %s: %v", hostname, err)
+	}
+}
+
+// InvalidateDomain drops the cached route of a custom domain.
+func (s *RedisService) InvalidateDomain(ctx context.Context, hostname string) {
+	if err := s.Delete(ctx, DomainCacheKey(hostname)); err != nil {
+		log.Printf("Failed to invalidate domain cache for %s: %v", hostname, err)
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"gorm.io/gorm"
+)
+
+// transientError marks failures worth retrying, e.g. network blips or
+// 5xx responses from the git host or object storage.
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+func transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
+
+// IsRetryable reports whether err was classified as transient.
+func IsRetryable(err error) bool {
+	var t *transientError
+	return errors.As(err, &t)
+}
+
+// classify wraps err as transient when isTransient recognises it.
+func classify(err error, isTransient func(error) bool) error {
+	if err != nil && isTransient(err) {
+		return transient(err)
+	}
+	return err
+}
+
+func isTransientNetworkError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+func isTransientCloneError(err error) bool {
+	switch {
+	case errors.Is(err, transport.ErrRepositoryNotFound),
+		errors.Is(err, transport.ErrEmptyRemoteRepository),
+		errors.Is(err, transport.ErrAuthenticationRequired),
+		errors.Is(err, transport.ErrAuthorizationFailed),
+		errors.Is(err, transport.ErrInvalidAuthMethod):
+		return false
+	}
+
+	var statusErr interface{ StatusCode() int }
+	if errors.As(err, &statusErr) {
+		return isTransientStatus(statusErr.StatusCode())
+	}
+	return isTransientNetworkError(err)
+}
+
+func isTransientStorageError(err error) bool {
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		return isTransientStatus(statusErr.HTTPStatusCode())
+	}
+	return isTransientNetworkError(err)
+}
+
+func isTransientDatabaseError(err error) bool {
+	return !errors.Is(err, gorm.ErrRecordNotFound)
+}
+
+func isTransientStatus(code int) bool {
+	return code >= 500 || code == 429 || code == 408
+}
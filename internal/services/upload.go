@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"deployment-platform/internal/archive"
+	"deployment-platform/internal/storage"
+)
+
+// ErrUnsupportedArchive is returned for uploads that are neither a gzipped
+// tar nor a zip archive.
+var ErrUnsupportedArchive = errors.New("archive must be a .tar.gz or .zip file")
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// uploadKey is where an uploaded archive waits for the worker.
+func uploadKey(deployID string) string {
+	return fmt.Sprintf("source/%s/archive", deployID)
+}
+
+// archiveType tells the supported formats apart by their magic bytes.
+func archiveType(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return "application/gzip"
+	case bytes.HasPrefix(header, zipMagic):
+		return "application/zip"
+	default:
+		return ""
+	}
+}
+
+// StoreUpload streams an uploaded archive of size bytes into object storage
+// for the deployment's build.
+func (s *DeployService) StoreUpload(ctx context.Context, deployID string, body io.Reader, size int64) error {
+	br := bufio.NewReader(body)
+	header, _ := br.Peek(len(zipMagic))
+	contentType := archiveType(header)
+	if contentType == "" {
+		return ErrUnsupportedArchive
+	}
+	return s.store.Put(ctx, uploadKey(deployID), br, size, contentType)
+}
+
+// CopyUpload gives a redeploy of an uploaded deployment its own copy of the
+// archive, so either can be deleted without affecting the other.
+func (s *DeployService) CopyUpload(ctx context.Context, fromID, toID string) error {
+	return s.store.Copy(ctx, uploadKey(fromID), uploadKey(toID))
+}
+
+// DiscardUpload removes an archive whose deployment was never created.
+func (s *DeployService) DiscardUpload(ctx context.Context, deployID string) error {
+	_, err := s.store.DeleteByPrefix(ctx, fmt.Sprintf("source/%s/", deployID))
+	return err
+}
+
+// extractUpload unpacks a deployment's uploaded archive into dest within
+// the worker's upload limits. Zip needs random access, so the archive is
+// spooled to a temporary file first.
+func (s *DeployService) extractUpload(ctx context.Context, deployID, dest string) error {
+	obj, err := s.store.Get(ctx, uploadKey(deployID), nil)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return errors.New("Uploaded archive not found")
+		}
+		return classify(fmt.Errorf("Download failed: %w", err), isTransientStorageError)
+	}
+	defer obj.Body.Close()
+
+	f, err := os.CreateTemp("", "gopher-upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	size, err := io.Copy(f, obj.Body)
+	if err != nil {
+		return classify(fmt.Errorf("Download failed: %w", err), isTransientStorageError)
+	}
+	header := make([]byte, len(zipMagic))
+	if _, err := f.ReadAt(header, 0); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return err
+	}
+	switch archiveType(header) {
+	case "application/gzip":
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		err = archive.ExtractTarGz(f, dest, s.opts.UploadLimits)
+	case "application/zip":
+		err = archive.ExtractZip(f, size, dest, s.opts.UploadLimits)
+	default:
+		err = ErrUnsupportedArchive
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid archive: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"deployment-platform/internal/models"
+	"deployment-platform/internal/services/deployer"
+	"deployment-platform/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea"
+)
+
+var (
+	ErrUnknownProvider  = errors.New("unknown webhook provider")
+	ErrInvalidPayload   = errors.New("invalid webhook payload")
+	ErrNoProject        = errors.New("no project is registered for this repository")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Delivery is an inbound webhook request. Signature holds the provider's
+// signature header, or the token for GitLab.
+type Delivery struct {
+	Provider  string
+	Event     string
+	Signature string
+	Body      []byte
+}
+
+// Result reports what a delivery triggered. Ignored explains deliveries
+// that were accepted but did not deploy anything; Errors lists projects
+// whose deployment could not be queued while others were.
+type Result struct {
+	Ignored     string               `json:"ignored,omitempty"`
+	Deployments []*models.Deployment `json:"deployments,omitempty"`
+	Errors      []string             `json:"errors,omitempty"`
+}
+
+type Service interface {
+	HandleDelivery(ctx context.Context, delivery Delivery) (*Result, error)
+}
+
+type service struct {
+	db          *gorm.DB
+	deployments deployer.Service
+}
+
+func NewService(db *gorm.DB, deployments deployer.Service) Service {
+	return &service{db: db, deployments: deployments}
+}
+
+// push is the provider-independent part of a push event.
+type push struct {
+	repoURLs  []string
+	ref       string
+	commitSHA string
+}
+
+// Pushes that delete a branch carry an all-zero "after" SHA
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// HandleDelivery deploys the pushed commit for every project registered
+// for the repository whose webhook secret verifies the delivery.
+func (s *service) HandleDelivery(ctx context.Context, delivery Delivery) (*Result, error) {
+	if !isPushEvent(delivery) {
+		return &Result{Ignored: fmt.Sprintf("event %q", delivery.Event)}, nil
+	}
+
+	p, err := parsePush(delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	projects, err := s.findProjects(p.repoURLs)
+	if err != nil {
+		return nil, err
+	}
+	if len(projects) == 0 {
+		return nil, ErrNoProject
+	}
+
+	var verified []models.Project
+	for _, project := range projects {
+		if verify(delivery, project.WebhookSecret) {
+			verified = append(verified, project)
+		}
+	}
+	if len(verified) == 0 {
+		return nil, ErrInvalidSignature
+	}
+
+	branch, ok := strings.CutPrefix(p.ref, "refs/heads/")
+	switch {
+	case !ok:
+		return &Result{Ignored: fmt.Sprintf("ref %q is not a branch", p.ref)}, nil
+	case p.commitSHA == "" || p.commitSHA == zeroSHA:
+		return &Result{Ignored: fmt.Sprintf("branch %q was deleted", branch)}, nil
+	}
+
+	// Once anything is queued the delivery must succeed: providers redeliver
+	// failed ones, which would deploy those projects a second time
+	result := &Result{}
+	var firstErr error
+	for _, project := range verified {
+		deployment, err := s.deployments.CreateDeployment(ctx, project.UserID, deployer.CreateDeploymentInput{
+			ProjectID: &project.ID,
+			Branch:    branch,
+			CommitSHA: p.commitSHA,
+		})
+		if err != nil {
+			err = fmt.Errorf("failed to queue deployment for project %d: %w", project.ID, err)
+			log.Printf("Push to %s@%s: %v", project.Slug, branch, err)
+			result.Errors = append(result.Errors, err.Error())
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		log.Printf("Push to %s@%s queued deployment %s (%s)", project.Slug, branch, deployment.DeployID, deployment.Target)
+		result.Deployments = append(result.Deployments, deployment)
+	}
+	if len(result.Deployments) == 0 {
+		return nil, firstErr
+	}
+	return result, nil
+}
+
+func (s *service) findProjects(repoURLs []string) ([]models.Project, error) {
+	keys := make([]string, 0, len(repoURLs))
+	for _, u := range repoURLs {
+		if u != "" {
+			keys = append(keys, utils.RepoKey(u))
+		}
+	}
+	if len(keys) == 0 {
+		return nil, ErrInvalidPayload
+	}
+
+	var projects []models.Project
+	if err := s.db.Where("repo_key IN ?", keys).Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+func isPushEvent(delivery Delivery) bool {
+	switch delivery.Provider {
+	case ProviderGitLab:
+		return delivery.Event == "Push Hook"
+	default:
+		return delivery.Event == "push"
+	}
+}
+
+// parsePush reads the push fields. GitHub and Gitea share a payload
+// shape; GitLab names the repository fields differently.
+func parsePush(delivery Delivery) (*push, error) {
+	var payload struct {
+		Ref        string `json:"ref"`
+		After      string `json:"after"`
+		Repository struct {
+			CloneURL string `json:"clone_url"`
+			HTMLURL  string `json:"html_url"`
+			SSHURL   string `json:"ssh_url"`
+		} `json:"repository"`
+		Project struct {
+			GitHTTPURL string `json:"git_http_url"`
+			GitSSHURL  string `json:"git_ssh_url"`
+			WebURL     string `json:"web_url"`
+		} `json:"project"`
+	}
+	if err := json.Unmarshal(delivery.Body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	p := &push{ref: payload.Ref, commitSHA: payload.After}
+	switch delivery.Provider {
+	case ProviderGitHub, ProviderGitea:
+		r := payload.Repository
+		p.repoURLs = []string{r.CloneURL, r.HTMLURL, r.SSHURL}
+	case ProviderGitLab:
+		r := payload.Project
+		p.repoURLs = []string{r.GitHTTPURL, r.GitSSHURL, r.WebURL}
+	default:
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// verify checks a delivery against a project's secret: an HMAC-SHA256 of
+// the body for GitHub ("sha256=<hex>") and Gitea ("<hex>"), the plain
+// secret for GitLab.
+func verify(delivery Delivery, secret string) bool {
+	if secret == "" || delivery.Signature == "" {
+		return false
+	}
+
+	switch delivery.Provider {
+	case ProviderGitLab:
+		return subtle.ConstantTimeCompare([]byte(delivery.Signature), []byte(secret)) == 1
+	case ProviderGitHub, ProviderGitea:
+		signature := strings.TrimPrefix(delivery.Signature, "sha256=")
+		got, err := hex.DecodeString(signature)
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(delivery.Body)
+		return hmac.Equal(got, mac.Sum(nil))
+	default:
+		return false
+	}
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FSStore keeps objects as files below a local directory, so the platform
+// can run offline and tests can point it at a temp dir.
+type FSStore struct {
+	root string
+}
+
+func NewFSStore(root string) (*FSStore, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, err
+	}
+	return &FSStore{root: abs}, nil
+}
+
+// path maps a key to a file below root, rejecting keys that would escape it.
+func (s *FSStore) path(key string) (string, error) {
+	p := filepath.Join(s.root, filepath.FromSlash(key))
+	if p != s.root && !strings.HasPrefix(p, s.root+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return p, nil
+}
+
+func (s *FSStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temp file first so readers never see partial objects
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *FSStore) Get(ctx context.Context, key string, rng *Range) (*Object, error) {
+	info, err := s.Head(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	p, _ := s.path(key)
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &Object{ObjectInfo: *info, Body: file}
+	if rng == nil {
+		return obj, nil
+	}
+
+	end := rng.End
+	if end < 0 || end >= info.Size {
+		end = info.Size - 1
+	}
+	if rng.Start < 0 || rng.Start > end {
+		file.Close()
+		return nil, fmt.Errorf("invalid range %d-%d for object of %d bytes", rng.Start, rng.End, info.Size)
+	}
+	if _, err := file.Seek(rng.Start, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	obj.Size = end - rng.Start + 1
+	obj.ContentRange = fmt.Sprintf("bytes %d-%d/%d", rng.Start, end, info.Size)
+	obj.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, obj.Size), file}
+
+	return obj, nil
+}
+
+func (s *FSStore) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrNotFound
+	}
+
+	return s.info(key, info), nil
+}
+
+func (s *FSStore) info(key string, info fs.FileInfo) *ObjectInfo {
+	// A cheap ETag from size and mtime, enough for cache validation
+	sum := md5.Sum([]byte(fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano())))
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         info.Size(),
+		ContentType:  contentTypeFor(key),
+		ETag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+		LastModified: info.ModTime(),
+	}
+}
+
+func (s *FSStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	// Only walk the directory the prefix points into
+	start := s.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir, err := s.path(prefix[:i])
+		if err != nil {
+			return nil, err
+		}
+		start = dir
+	}
+	if _, err := os.Stat(start); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, *s.info(key, info))
+		return nil
+	})
+
+	return objects, err
+}
+
+func (s *FSStore) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, obj := range objects {
+		p, _ := s.path(obj.Key)
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+func (s *FSStore) Copy(ctx context.Context, srcKey, dstKey string) error {
+	obj, err := s.Get(ctx, srcKey, nil)
+	if err != nil {
+		return err
+	}
+	defer obj.Body.Close()
+
+	return s.Put(ctx, dstKey, obj.Body, obj.Size, obj.ContentType)
+}
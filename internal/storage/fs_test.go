@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func newTestStore(t *testing.T) *FSStore {
+	t.Helper()
+	store, err := NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func put(t *testing.T, store ObjectStore, key, body string) {
+	t.Helper()
+	if err := store.Put(context.Background(), key, strings.NewReader(body), int64(len(body)), contentTypeFor(key)); err != nil {
+		t.Fatalf("Put(%s): %v", key, err)
+	}
+}
+
+func read(t *testing.T, store ObjectStore, key string, rng *Range) (*Object, string) {
+	t.Helper()
+	obj, err := store.Get(context.Background(), key, rng)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", key, err)
+	}
+	defer obj.Body.Close()
+	body, err := io.ReadAll(obj.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj, string(body)
+}
+
+func keys(objects []ObjectInfo) []string {
+	var out []string
+	for _, obj := range objects {
+		out = append(out, obj.Key)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestFSStorePutGetHead(t *testing.T) {
+	store := newTestStore(t)
+	put(t, store, "deployments/abc/index.html", "<h1>hello</h1>")
+
+	obj, body := read(t, store, "deployments/abc/index.html", nil)
+	if body != "<h1>hello</h1>" {
+		t.Errorf("body = %q", body)
+	}
+	if obj.Size != 14 || obj.ContentType != "text/html; charset=utf-8" || obj.ETag == "" {
+		t.Errorf("unexpected object info %+v", obj.ObjectInfo)
+	}
+
+	info, err := store.Head(context.Background(), "deployments/abc/index.html")
+	if err != nil || info.ETag != obj.ETag {
+		t.Errorf("Head = %+v, %v; want ETag %s", info, err, obj.ETag)
+	}
+
+	put(t, store, "deployments/abc/index.html", "changed")
+	if _, body := read(t, store, "deployments/abc/index.html", nil); body != "changed" {
+		t.Errorf("overwritten body = %q", body)
+	}
+}
+
+func TestFSStoreNotFound(t *testing.T) {
+	store := newTestStore(t)
+	put(t, store, "deployments/abc/index.html", "x")
+
+	for _, key := range []string{"missing", "deployments/abc", "deployments"} {
+		if _, err := store.Head(context.Background(), key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Head(%s) = %v, want ErrNotFound", key, err)
+		}
+		if _, err := store.Get(context.Background(), key, nil); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%s) = %v, want ErrNotFound", key, err)
+		}
+	}
+}
+
+func TestFSStoreRejectsEscapingKeys(t *testing.T) {
+	parent := t.TempDir()
+	store, err := NewFSStore(filepath.Join(parent, "objects"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"../outside", "a/../../outside", "../objects-sibling/x"} {
+		err := store.Put(context.Background(), key, strings.NewReader("x"), 1, "")
+		if err == nil {
+			t.Errorf("Put(%s) succeeded", key)
+		}
+	}
+	entries, _ := os.ReadDir(parent)
+	if len(entries) != 1 {
+		t.Errorf("Put wrote outside the store root: %v", entries)
+	}
+}
+
+func TestFSStoreRangedGet(t *testing.T) {
+	store := newTestStore(t)
+	put(t, store, "file.txt", "0123456789")
+
+	tests := []struct {
+		rng       Range
+		wantBody  string
+		wantRange string
+	}{
+		{Range{0, 3}, "0123", "bytes 0-3/10"},
+		{Range{5, -1}, "56789", "bytes 5-9/10"},
+		{Range{8, 100}, "89", "bytes 8-9/10"},
+		{Range{9, 9}, "9", "bytes 9-9/10"},
+	}
+	for _, tt := range tests {
+		obj, body := read(t, store, "file.txt", &tt.rng)
+		if body != tt.wantBody || obj.ContentRange != tt.wantRange || obj.Size != int64(len(tt.wantBody)) {
+			t.Errorf("range %+v = %q %q size %d; want %q %q", tt.rng, body, obj.ContentRange, obj.Size, tt.wantBody, tt.wantRange)
+		}
+	}
+
+	for _, rng := range []Range{{10, -1}, {5, 3}, {-1, 2}} {
+		if _, err := store.Get(context.Background(), "file.txt", &rng); err == nil {
+			t.Errorf("range %+v accepted", rng)
+		}
+	}
+}
+
+func TestFSStoreListAndDeleteByPrefix(t *testing.T) {
+	store := newTestStore(t)
+	for _, key := range []string{
+		"deployments/abc/index.html",
+		"deployments/abc/assets/app.js",
+		"deployments/abcd/index.html",
+		"deployments/xyz/index.html",
+		"sources/abc.tar.gz",
+	} {
+		put(t, store, key, key)
+	}
+
+	objects, err := store.List(context.Background(), "deployments/abc/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := keys(objects), []string{"deployments/abc/assets/app.js", "deployments/abc/index.html"}; !slices.Equal(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+
+	objects, _ = store.List(context.Background(), "deployments/abc")
+	if got := keys(objects); len(got) != 3 {
+		t.Errorf("List without trailing slash = %v, want abc and abcd objects", got)
+	}
+	if objects, err := store.List(context.Background(), "missing/"); err != nil || len(objects) != 0 {
+		t.Errorf("List(missing/) = %v, %v", objects, err)
+	}
+
+	deleted, err := store.DeleteByPrefix(context.Background(), "deployments/abc/")
+	if err != nil || deleted != 2 {
+		t.Fatalf("DeleteByPrefix = %d, %v; want 2", deleted, err)
+	}
+	objects, _ = store.List(context.Background(), "")
+	want := []string{"deployments/abcd/index.html", "deployments/xyz/index.html", "sources/abc.tar.gz"}
+	if got := keys(objects); !slices.Equal(got, want) {
+		t.Errorf("after delete List = %v, want %v", got, want)
+	}
+}
+
+func TestFSStoreCopy(t *testing.T) {
+	store := newTestStore(t)
+	put(t, store, "deployments/abc/index.html", "hello")
+
+	if err := store.Copy(context.Background(), "deployments/abc/index.html", "aliases/www/index.html"); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := read(t, store, "aliases/www/index.html", nil); body != "hello" {
+		t.Errorf("copied body = %q", body)
+	}
+	if err := store.Copy(context.Background(), "missing", "x"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Copy(missing) = %v, want ErrNotFound", err)
+	}
+}
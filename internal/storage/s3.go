@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"deployment-platform/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3Store works with AWS S3 and compatible services such as R2 and MinIO.
+type S3Store struct {
+	client *s3.Client
+	bucket string
+}
+
+func NewS3Store(cfg *config.Config) *S3Store {
+	creds := credentials.NewStaticCredentialsProvider(cfg.S3AccessKey, cfg.S3SecretKey, "")
+
+	s3Config := aws.Config{
+		Region:      "auto",
+		Credentials: creds,
+		EndpointResolver: aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
+			return aws.Endpoint{
+				URL:           cfg.S3Endpoint,
+				SigningRegion: "auto",
+			}, nil
+		}),
+	}
+
+	return &S3Store{
+		client: s3.NewFromConfig(s3Config),
+		bucket: cfg.S3Bucket,
+	}
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	}
+	if size >= 0 {
+		input.ContentLength = aws.Int64(size)
+	}
+
+	_, err := s.client.PutObject(ctx, input)
+	return err
+}
+
+func (s *S3Store) Get(ctx context.Context, key string, rng *Range) (*Object, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	if rng != nil {
+		if rng.End >= 0 {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", rng.Start, rng.End))
+		} else {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-", rng.Start))
+		}
+	}
+
+	output, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		return nil, mapS3Error(err)
+	}
+
+	return &Object{
+		ObjectInfo: ObjectInfo{
+			Key:          key,
+			Size:         aws.ToInt64(output.ContentLength),
+			ContentType:  aws.ToString(output.ContentType),
+			ETag:         aws.ToString(output.ETag),
+			LastModified: aws.ToTime(output.LastModified),
+		},
+		Body:         output.Body,
+		ContentRange: aws.ToString(output.ContentRange),
+	}, nil
+}
+
+func (s *S3Store) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	output, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, mapS3Error(err)
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(output.ContentLength),
+		ContentType:  aws.ToString(output.ContentType),
+		ETag:         aws.ToString(output.ETag),
+		LastModified: aws.ToTime(output.LastModified),
+	}, nil
+}
+
+func (s *S3Store) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				ETag:         aws.ToString(obj.ETag),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+
+	return objects, nil
+}
+
+func (s *S3Store) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	// DeleteObjects accepts at most 1000 keys per request
+	for start := 0; start < len(objects); start += 1000 {
+		end := min(start+1000, len(objects))
+
+		ids := make([]types.ObjectIdentifier, 0, end-start)
+		for _, obj := range objects[start:end] {
+			ids = append(ids, types.ObjectIdentifier{Key: aws.String(obj.Key)})
+		}
+
+		output, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &types.Delete{Objects: ids, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return deleted, err
+		}
+		if len(output.Errors) > 0 {
+			return deleted, fmt.Errorf("failed to delete %s: %s", aws.ToString(output.Errors[0].Key), aws.ToString(output.Errors[0].Message))
+		}
+		deleted += len(ids)
+	}
+
+	return deleted, nil
+}
+
+func (s *S3Store) Copy(ctx context.Context, srcKey, dstKey string) error {
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(dstKey),
+		CopySource: aws.String(s.bucket + "/" + escapeKey(srcKey)),
+	})
+	return mapS3Error(err)
+}
+
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+func mapS3Error(err error) error {
+	var noSuchKey *types.NoSuchKey
+	var notFound *types.NotFound
+	if errors.As(err, &noSuchKey) || errors.As(err, &notFound) {
+		return ErrNotFound
+	}
+	return err
+}
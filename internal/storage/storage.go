@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"deployment-platform/internal/config"
+)
+
+const (
+	DriverS3 = "s3"
+	DriverFS = "fs"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Range selects bytes Start through End inclusive; a negative End reads to
+// the end of the object.
+type Range struct {
+	Start int64
+	End   int64
+}
+
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"content_type,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Object is an open object; callers must close Body.
+type Object struct {
+	ObjectInfo
+	Body io.ReadCloser
+	// ContentRange is set for ranged reads, e.g. "bytes 0-99/1234"
+	ContentRange string
+}
+
+// ObjectStore holds deployment sources and build artifacts.
+type ObjectStore interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string, rng *Range) (*Object, error)
+	Head(ctx context.Context, key string) (*ObjectInfo, error)
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	DeleteByPrefix(ctx context.Context, prefix string) (int, error)
+	Copy(ctx context.Context, srcKey, dstKey string) error
+}
+
+// New builds the backend selected by STORAGE_DRIVER.
+func New(cfg *config.Config) (ObjectStore, error) {
+	switch cfg.StorageDriver {
+	case DriverS3:
+		return NewS3Store(cfg), nil
+	case DriverFS:
+		return NewFSStore(cfg.StorageDir)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+func contentTypeFor(name string) string {
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return contentType
+}
+
+func UploadFile(ctx context.Context, store ObjectStore, filePath, key string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	return store.Put(ctx, key, file, info.Size(), contentTypeFor(filePath))
+}
+
+// UploadDirectory copies every file below dirPath to prefix, skipping .git.
+func UploadDirectory(ctx context.Context, store ObjectStore, dirPath, prefix string) error {
+	root, err := os.OpenRoot(dirPath)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	return UploadRoot(ctx, store, root, prefix)
+}
+
+// UploadRoot copies every regular file below root to prefix, skipping .git.
+// Files are opened through root, so symlinks cannot lead outside it.
+func UploadRoot(ctx context.Context, store ObjectStore, root *os.Root, prefix string) error {
+	return fs.WalkDir(root.FS(), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// Never publish repository metadata, e.g. for static sites served from the repo root
+			if d.Name() == ".git" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		file, err := root.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		return store.Put(ctx, path.Join(prefix, name), file, info.Size(), contentTypeFor(name))
+	})
+}
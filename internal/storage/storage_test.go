@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, body := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUploadDirectory(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"index.html":       "<h1>hi</h1>",
+		"assets/app.js":    "console.log(1)",
+		"assets/style.css": "body{}",
+		".git/config":      "[core]",
+		"sub/.git/HEAD":    "ref: refs/heads/main",
+	})
+	secret := filepath.Join(t.TempDir(), "secret")
+	writeFiles(t, filepath.Dir(secret), map[string]string{"secret": "password"})
+	if err := os.Symlink(secret, filepath.Join(src, "leak.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Dir(secret), filepath.Join(src, "leakdir")); err != nil {
+		t.Fatal(err)
+	}
+
+	store := newTestStore(t)
+	if err := UploadDirectory(context.Background(), store, src, "deployments/abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	objects, err := store.List(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"deployments/abc/assets/app.js",
+		"deployments/abc/assets/style.css",
+		"deployments/abc/index.html",
+	}
+	if got := keys(objects); !slices.Equal(got, want) {
+		t.Fatalf("uploaded %v, want %v", got, want)
+	}
+
+	obj, body := read(t, store, "deployments/abc/assets/app.js", nil)
+	if body != "console.log(1)" {
+		t.Errorf("app.js = %q", body)
+	}
+	if obj.ContentType != "text/javascript; charset=utf-8" {
+		t.Errorf("app.js content type = %q", obj.ContentType)
+	}
+}
+
+func TestUploadRootStaysInsideRoot(t *testing.T) {
+	workspace := t.TempDir()
+	writeFiles(t, workspace, map[string]string{
+		"dist/index.html": "ok",
+		"secret.env":      "TOKEN=1",
+	})
+	// A build can leave symlinks pointing back into the workspace
+	if err := os.Symlink("../secret.env", filepath.Join(workspace, "dist", "env.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	ws, err := os.OpenRoot(workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	dist, err := ws.OpenRoot("dist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dist.Close()
+
+	store := newTestStore(t)
+	if err := UploadRoot(context.Background(), store, dist, "deployments/abc"); err != nil {
+		t.Fatal(err)
+	}
+	objects, _ := store.List(context.Background(), "")
+	if got := keys(objects); !slices.Equal(got, []string{"deployments/abc/index.html"}) {
+		t.Errorf("uploaded %v", got)
+	}
+}
+
+func TestUploadDirectoryCancelled(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{"index.html": "x"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	store := newTestStore(t)
+	if err := UploadDirectory(ctx, store, src, "deployments/abc"); err == nil {
+		t.Fatal("upload succeeded with a cancelled context")
+	}
+	if objects, _ := store.List(context.Background(), ""); len(objects) != 0 {
+		t.Errorf("uploaded %v after cancellation", keys(objects))
+	}
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+)
+
+// RepoKey normalises a git remote URL to "host/owner/repo" so the same
+// repository matches whether it is given as HTTPS, SSH or scp-like syntax,
+// with or without ".git".
+func RepoKey(repoURL string) string {
+	host, path, ok := splitRemote(repoURL)
+	if !ok {
+		return strings.ToLower(strings.TrimSuffix(strings.Trim(strings.TrimSpace(repoURL), "/"), ".git"))
+	}
+	return strings.ToLower(host + "/" + strings.TrimSuffix(path, ".git"))
+}
+
+// IsSSHRemote reports whether repoURL is an ssh:// or scp-like remote.
+func IsSSHRemote(repoURL string) bool {
+	raw := strings.TrimSpace(repoURL)
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		return u.Scheme == "ssh" || u.Scheme == "git+ssh"
+	}
+	_, _, ok := splitRemote(raw)
+	return ok
+}
+
+// SSHURL rewrites an HTTPS remote to ssh://user@host/path, leaving SSH
+// remotes untouched.
+func SSHURL(repoURL, user string) string {
+	if IsSSHRemote(repoURL) {
+		return repoURL
+	}
+	host, path, ok := splitRemote(repoURL)
+	if !ok {
+		return repoURL
+	}
+	return (&url.URL{Scheme: "ssh", User: url.User(user), Host: host, Path: "/" + path}).String()
+}
+
+// HTTPSURL rewrites SSH and scp-like remotes to https://host/path, leaving
+// HTTP(S) remotes untouched.
+func HTTPSURL(repoURL string) string {
+	if !IsSSHRemote(repoURL) {
+		return repoURL
+	}
+	host, path, ok := splitRemote(repoURL)
+	if !ok {
+		return repoURL
+	}
+	return (&url.URL{Scheme: "https", Host: host, Path: "/" + path}).String()
+}
+
+// splitRemote returns the host (without port) and the slash-trimmed path of
+// a URL or scp-like remote such as git@github.com:owner/repo.git.
+func splitRemote(repoURL string) (host, path string, ok bool) {
+	raw := strings.TrimSpace(repoURL)
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+	} else if at := strings.Index(raw, "@"); at >= 0 && strings.Contains(raw[at:], ":") {
+		host, path, _ = strings.Cut(raw[at+1:], ":")
+	} else {
+		return "", "", false
+	}
+	return host, strings.Trim(path, "/"), true
+}
@@ -1,10 +1,39 @@
 package utils
 
 import (
+	"path"
+	"reflect"
+	"strings"
+
 	"github.com/go-playground/validator/v10"
 )
 
-var validate = validator.New()
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	// Report fields by their JSON names so errors match what users wrote
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	// relpath accepts a relative path that stays inside its parent directory
+	v.RegisterValidation("relpath", func(fl validator.FieldLevel) bool {
+		p := fl.Field().String()
+		if strings.HasPrefix(p, "/") || strings.Contains(p, "\\") {
+			return false
+		}
+		clean := path.Clean(p)
+		return clean != ".." && !strings.HasPrefix(clean, "../")
+	})
+
+	return v
+}
 
 func ValidateInput(data interface{}) error {
 	return validate.Struct(data)